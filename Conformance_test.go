@@ -0,0 +1,40 @@
+package filesystem_test
+
+import (
+	"testing"
+
+	"github.com/gouniverse/filesystem"
+	"github.com/gouniverse/filesystem/filesystemtest"
+)
+
+func TestMemoryStorageConformance(t *testing.T) {
+	filesystemtest.RunConformance(t, filesystem.NewMemoryTestStorage)
+}
+
+func TestLocalStorageConformance(t *testing.T) {
+	filesystemtest.RunConformance(t, filesystem.NewLocalTestStorage)
+}
+
+func TestSqlStorageConformance(t *testing.T) {
+	filesystemtest.RunConformance(t, filesystem.NewSqlTestStorage)
+}
+
+func TestSqlStorageChunkedConformance(t *testing.T) {
+	filesystemtest.RunConformance(t, filesystem.NewSqlChunkedTestStorage)
+}
+
+func TestS3StorageConformance(t *testing.T) {
+	filesystemtest.RunConformance(t, func(t *testing.T) filesystem.StorageInterface {
+		storage, err := filesystem.NewStorage(filesystem.NewFakeS3Disk(t))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		return storage
+	})
+}
+
+func TestStaticStorageConformance(t *testing.T) {
+	filesystemtest.RunReadOnlyConformance(t, filesystem.NewStaticTestStorage)
+}
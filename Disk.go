@@ -1,6 +1,13 @@
 package filesystem
 
-import "database/sql"
+import (
+	"database/sql"
+	"io/fs"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
 
 type Disk struct {
 	DiskName   string
@@ -12,8 +19,19 @@ type Disk struct {
 	DB        *sql.DB // for sql
 	TableName string  // for sql
 
+	// Stores the contents as BLOB chunks, instead of base64 encoded text
+	ChunkedContents bool // for sql
+	ChunkSize       int  // for sql, defaults to DEFAULT_SQL_CHUNK_SIZE
+
 	// Local options
-	Root string // for local filesystem (not implemented)
+	Root string // for local filesystem, the directory all paths are relative to
+
+	// Static options
+	HTTPClient   *http.Client // for static, defaults to http.DefaultClient
+	ManifestPath string       // for static, the path of the JSON manifest (relative to Url), enables listing
+
+	// FS options
+	FS fs.FS // for fs, i.e. embed.FS, os.DirFS or zip.Reader
 
 	// S3 options
 	Key      string // for s3
@@ -22,10 +40,26 @@ type Disk struct {
 	Bucket   string // for s3
 	Endpoint string // for s3
 
+	// Optional, a preconfigured client (i.e. with a custom HTTP transport or
+	// retryer), or an AWS config (i.e. loaded with config.LoadDefaultConfig,
+	// to use IAM roles, environment variables or web identity credentials).
+	// When set, Key and Secret are not required. With AWSConfig, the
+	// endpoint is resolved by the SDK, or set in Endpoint, and the Url
+	// is only used for the URLs of the files.
+	S3Client  *s3.Client  // for s3
+	AWSConfig *aws.Config // for s3
+
 	// Allows you to enable the client to use path-style addressing, i.e.,
 	// https://s3.amazonaws.com/BUCKET/KEY . By default, the S3 client will use virtual
 	// hosted bucket addressing when possible( https://BUCKET.s3.amazonaws.com/KEY ).
 	UsePathStyleEndpoint bool // for s3
 
+	// Uploads larger than the threshold are split in parts, which are
+	// uploaded in parallel. S3 requires parts of at least 5 MB (except
+	// the last one), and allows at most 10000 parts per upload.
+	MultipartThreshold   int64 // for s3, defaults to 64 MB
+	MultipartPartSize    int64 // for s3, defaults to 16 MB, at least 5 MB
+	MultipartConcurrency int   // for s3, defaults to 4
+
 	Throw bool // for s3 (not implemented)
 }
@@ -0,0 +1,219 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// FSStorage implements StorageInterface on top of an fs.FS,
+// i.e. embed.FS, os.DirFS or zip.Reader. It is read only,
+// the write methods return ErrReadOnly.
+type FSStorage struct {
+	disk Disk
+}
+
+var _ StorageInterface = (*FSStorage)(nil)     // verify it extends the storage interface
+var _ StorageStatInterface = (*FSStorage)(nil) // verify it extends the storage stat interface
+var _ StorageCopyInterface = (*FSStorage)(nil) // verify it extends the storage copy interface
+
+// fsDriver creates the storage of a DRIVER_FS disk. The FS is
+// set in code, so it is not checked by the validation.
+func fsDriver(disk Disk) (StorageInterface, error) {
+	if disk.FS == nil {
+		return nil, errors.New("fs is required field")
+	}
+
+	return &FSStorage{disk: disk}, nil
+}
+
+// NewFSStorage creates a read only storage serving the files of fsys,
+// with the URLs of the files relative to url
+func NewFSStorage(fsys fs.FS, url string) *FSStorage {
+	return &FSStorage{disk: Disk{Driver: DRIVER_FS, FS: fsys, Url: url}}
+}
+
+func (s *FSStorage) Copy(originFile, targetFile string) error {
+	return newPathError("copy", originFile, ErrReadOnly)
+}
+
+func (s *FSStorage) CopyDirectory(sourceDir, targetDir string) error {
+	return newPathError("copy", sourceDir, ErrReadOnly)
+}
+
+func (s *FSStorage) CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	return newPathError("copy", sourceDir, ErrReadOnly)
+}
+
+func (s *FSStorage) DeleteFile(filePaths []string) error {
+	return newPathError("delete", strings.Join(filePaths, ", "), ErrReadOnly)
+}
+
+func (s *FSStorage) DeleteDirectory(dirPath string) error {
+	return newPathError("delete", dirPath, ErrReadOnly)
+}
+
+// Directories lists the sub-directories in the specified directory
+func (s *FSStorage) Directories(dirPath string) ([]string, error) {
+	return s.list(dirPath, true)
+}
+
+func (s *FSStorage) Exists(filePath string) (bool, error) {
+	_, err := fs.Stat(s.disk.FS, s.fsPath(filePath))
+
+	if err == nil {
+		return true, nil
+	}
+
+	err = osError("exists", filePath, err)
+
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// Files lists the files in the specified directory
+func (s *FSStorage) Files(dirPath string) ([]string, error) {
+	return s.list(dirPath, false)
+}
+
+func (s *FSStorage) MakeDirectory(dirPath string) error {
+	return newPathError("mkdir", dirPath, ErrReadOnly)
+}
+
+func (s *FSStorage) LastModified(filePath string) (time.Time, error) {
+	info, err := fs.Stat(s.disk.FS, s.fsPath(filePath))
+
+	if err != nil {
+		return time.Time{}, osError("lastmodified", filePath, err)
+	}
+
+	return info.ModTime(), nil
+}
+
+func (s *FSStorage) Move(originFile, targetFile string) error {
+	return newPathError("move", originFile, ErrReadOnly)
+}
+
+func (s *FSStorage) Put(filePath string, content []byte) error {
+	return newPathError("put", filePath, ErrReadOnly)
+}
+
+func (s *FSStorage) ReadFile(filePath string) ([]byte, error) {
+	info, err := fs.Stat(s.disk.FS, s.fsPath(filePath))
+
+	if err != nil {
+		return nil, osError("read", filePath, err)
+	}
+
+	if info.IsDir() {
+		return nil, newPathError("read", filePath, ErrNotFile)
+	}
+
+	data, err := fs.ReadFile(s.disk.FS, s.fsPath(filePath))
+
+	if err != nil {
+		return nil, osError("read", filePath, err)
+	}
+
+	return data, nil
+}
+
+func (s *FSStorage) Size(filePath string) (int64, error) {
+	info, err := fs.Stat(s.disk.FS, s.fsPath(filePath))
+
+	if err != nil {
+		return -1, osError("size", filePath, err)
+	}
+
+	if info.IsDir() {
+		return -1, newPathError("size", filePath, ErrNotFile)
+	}
+
+	return info.Size(), nil
+}
+
+// Stat returns the metadata of a file or a directory
+func (s *FSStorage) Stat(filePath string) (FileInfo, error) {
+	info, err := fs.Stat(s.disk.FS, s.fsPath(filePath))
+
+	if err != nil {
+		return FileInfo{}, osError("stat", filePath, err)
+	}
+
+	return s.fileInfo(cleanPath(filePath), info), nil
+}
+
+// List lists the files and sub-directories of the directory with their metadata
+func (s *FSStorage) List(dirPath string) ([]FileInfo, error) {
+	entries, err := fs.ReadDir(s.disk.FS, s.fsPath(dirPath))
+
+	if err != nil {
+		return []FileInfo{}, osError("list", dirPath, err)
+	}
+
+	infos := []FileInfo{}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+
+		if err != nil {
+			return []FileInfo{}, osError("list", dirPath, err)
+		}
+
+		infos = append(infos, s.fileInfo(path.Join(cleanPath(dirPath), entry.Name()), info))
+	}
+
+	sortFileInfos(infos)
+
+	return infos, nil
+}
+
+func (s *FSStorage) Url(filePath string) (string, error) {
+	return joinUrl(s.disk.Url, cleanPath(filePath)), nil
+}
+
+func (s *FSStorage) fileInfo(filePath string, info fs.FileInfo) FileInfo {
+	if info.IsDir() {
+		return newFileInfo(diskVisibility(s.disk), filePath, true, 0, info.ModTime())
+	}
+
+	return newFileInfo(diskVisibility(s.disk), filePath, false, info.Size(), info.ModTime())
+}
+
+// list lists the entries of a directory, either only the sub-directories
+// or only the files, as paths relative to the root
+func (s *FSStorage) list(dirPath string, directories bool) ([]string, error) {
+	entries, err := fs.ReadDir(s.disk.FS, s.fsPath(dirPath))
+
+	if err != nil {
+		return []string{}, osError("list", dirPath, err)
+	}
+
+	paths := []string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() != directories {
+			continue
+		}
+
+		paths = append(paths, path.Join(cleanPath(dirPath), entry.Name()))
+	}
+
+	return paths, nil
+}
+
+// fsPath converts a storage path to an fs path, where "." is the root
+func (s *FSStorage) fsPath(filePath string) string {
+	filePath = cleanPath(filePath)
+
+	if filePath == "" {
+		return "."
+	}
+
+	return filePath
+}
@@ -0,0 +1,146 @@
+package filesystem
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func fsStorageInit(t *testing.T) StorageInterface {
+	storage, err := NewStorage(Disk{
+		DiskName: "assets",
+		Driver:   DRIVER_FS,
+		Url:      "http://localhost/assets",
+		FS: fstest.MapFS{
+			"logo.svg":         {Data: []byte("<svg></svg>"), ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			"css/site.css":     {Data: []byte("body{}")},
+			"css/vendor/a.css": {Data: []byte("a{}")},
+			"js/app.js":        {Data: []byte("app()")},
+		},
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return storage
+}
+
+func TestFSStorageRead(t *testing.T) {
+	s := fsStorageInit(t)
+
+	data, err := s.ReadFile("/css/site.css")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "body{}" {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	size, err := s.Size("logo.svg")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if size != 11 {
+		t.Fatal("unexpected size:", size)
+	}
+
+	modified, err := s.LastModified("logo.svg")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !modified.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatal("unexpected last modified:", modified)
+	}
+
+	if _, err := s.ReadFile("css"); !errors.Is(err, ErrNotFile) {
+		t.Fatal("expected ErrNotFile, found:", err)
+	}
+
+	if _, err := s.ReadFile("missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+
+	url, err := s.Url("js/app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if url != "http://localhost/assets/js/app.js" {
+		t.Fatal("unexpected url:", url)
+	}
+}
+
+func TestFSStorageList(t *testing.T) {
+	s := fsStorageInit(t)
+
+	files, err := s.Files("")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(files) != 1 || files[0] != "logo.svg" {
+		t.Fatal("unexpected files:", files)
+	}
+
+	directories, err := s.Directories("css")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(directories) != 1 || directories[0] != "css/vendor" {
+		t.Fatal("unexpected directories:", directories)
+	}
+
+	for filePath, expected := range map[string]bool{"css/vendor/a.css": true, "css": true, "missing.txt": false} {
+		exists, err := s.Exists(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if exists != expected {
+			t.Fatal("unexpected exists for", filePath, exists)
+		}
+	}
+}
+
+func TestFSStorageReadOnly(t *testing.T) {
+	s := fsStorageInit(t)
+
+	errs := []error{
+		s.Put("new.txt", []byte("new")),
+		s.Copy("logo.svg", "copy.svg"),
+		s.Move("logo.svg", "moved.svg"),
+		s.MakeDirectory("dir"),
+		s.DeleteFile([]string{"logo.svg"}),
+		s.DeleteDirectory("css"),
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Fatal("expected ErrReadOnly, found:", err)
+		}
+	}
+}
+
+func TestFSStorageAsFS(t *testing.T) {
+	fsys := AsFS(NewFSStorage(fstest.MapFS{
+		"index.html":   {Data: []byte("<html></html>")},
+		"img/logo.png": {Data: []byte("png")},
+	}, "http://localhost"))
+
+	if err := fstest.TestFS(fsys, "index.html", "img/logo.png"); err != nil {
+		t.Fatal(err)
+	}
+}
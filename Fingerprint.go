@@ -0,0 +1,122 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// FINGERPRINT_LENGTH is the number of hex characters of the content hash in fingerprinted paths
+const FINGERPRINT_LENGTH = 8
+
+// DEFAULT_MANIFEST_PATH is the path the manifest is written to by Publish, by default
+const DEFAULT_MANIFEST_PATH = "manifest.json"
+
+// PublishOptions are the options of Publish
+type PublishOptions struct {
+	// Dir is the source directory to publish, the root by default.
+	// The paths in the manifest, and on the target, are relative to it.
+	Dir string
+
+	// ManifestPath is the path of the manifest on the target,
+	// DEFAULT_MANIFEST_PATH by default
+	ManifestPath string
+
+	// Static is the static storage serving the target, if any. Its
+	// manifest is replaced with the published one, so its URLs are
+	// not stale until the storage is created again.
+	Static *StaticStorage
+}
+
+// FingerprintPath returns the path with a hash of the content inserted
+// before the extension, i.e. "js/app.js" becomes "js/app.3f9a1c2b.js".
+// The hash of a dotfile without extension is appended, i.e. ".env"
+// becomes ".env.3f9a1c2b".
+func FingerprintPath(filePath string, content []byte) string {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])[:FINGERPRINT_LENGTH]
+
+	dir, name := path.Split(filePath)
+	extension := path.Ext(name)
+
+	// the leading dot of a dotfile is not an extension separator
+	if extension == name {
+		extension = ""
+	}
+
+	return dir + strings.TrimSuffix(name, extension) + "." + hash + extension
+}
+
+// Publish copies the files of the source directory to the target, under
+// their fingerprinted paths, and writes the manifest mapping the original
+// paths to the fingerprinted ones. Files already on the target are not
+// uploaded again, as the same path means the same content.
+//
+// Point a static disk to the target, with the manifest as its
+// ManifestPath, to get the fingerprinted URLs from Url.
+func Publish(source, target StorageInterface, options PublishOptions) (Manifest, error) {
+	manifestPath := options.ManifestPath
+
+	if manifestPath == "" {
+		manifestPath = DEFAULT_MANIFEST_PATH
+	}
+
+	dir := cleanPath(options.Dir)
+
+	files, err := allFiles(source, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{}
+
+	for _, file := range files {
+		content, err := source.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filePath := file
+
+		if dir != "" {
+			filePath = strings.TrimPrefix(file, dir+"/")
+		}
+
+		publishedPath := FingerprintPath(filePath, content)
+		manifest[filePath] = publishedPath
+
+		exists, err := target.Exists(publishedPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			continue
+		}
+
+		if err := target.Put(publishedPath, content); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := target.Put(manifestPath, data); err != nil {
+		return nil, err
+	}
+
+	if options.Static != nil {
+		options.Static.SetManifest(manifest)
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,194 @@
+package filesystem
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintPath(t *testing.T) {
+	fingerprinted := FingerprintPath("js/app.js", []byte("console.log('app')"))
+
+	if !regexp.MustCompile(`^js/app\.[0-9a-f]{8}\.js$`).MatchString(fingerprinted) {
+		t.Fatal("unexpected fingerprinted path:", fingerprinted)
+	}
+
+	if FingerprintPath("js/app.js", []byte("console.log('app')")) != fingerprinted {
+		t.Fatal("expected the same content to give the same path")
+	}
+
+	if FingerprintPath("js/app.js", []byte("console.log('changed')")) == fingerprinted {
+		t.Fatal("expected a different content to give a different path")
+	}
+
+	if !regexp.MustCompile(`^LICENSE\.[0-9a-f]{8}$`).MatchString(FingerprintPath("LICENSE", []byte("MIT"))) {
+		t.Fatal("unexpected fingerprinted path:", FingerprintPath("LICENSE", []byte("MIT")))
+	}
+
+	dotfiles := map[string]string{
+		".env":                `^\.env\.[0-9a-f]{8}$`,
+		"config/.env":         `^config/\.env\.[0-9a-f]{8}$`,
+		"config/.eslintrc.js": `^config/\.eslintrc\.[0-9a-f]{8}\.js$`,
+		"v1.2/LICENSE":        `^v1\.2/LICENSE\.[0-9a-f]{8}$`,
+	}
+
+	for filePath, pattern := range dotfiles {
+		if fingerprinted := FingerprintPath(filePath, []byte("test")); !regexp.MustCompile(pattern).MatchString(fingerprinted) {
+			t.Fatal("unexpected fingerprinted path:", fingerprinted)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	source := NewMemoryTestStorage(t)
+	target := NewMemoryTestStorage(t)
+
+	if err := source.Put("public/app.js", []byte("console.log('app')")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := source.Put("public/css/site.css", []byte("body{}")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := source.Put("private.txt", []byte("secret")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	manifest, err := Publish(source, target, PublishOptions{Dir: "public"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(manifest) != 2 {
+		t.Fatal("unexpected manifest:", manifest)
+	}
+
+	if manifest["app.js"] != FingerprintPath("app.js", []byte("console.log('app')")) {
+		t.Fatal("unexpected manifest:", manifest)
+	}
+
+	data, err := target.ReadFile(manifest["css/site.css"])
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "body{}" {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	published, err := LoadManifest(target, DEFAULT_MANIFEST_PATH)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if published.Path("/css/site.css") != manifest["css/site.css"] {
+		t.Fatal("unexpected published manifest:", published)
+	}
+
+	// publishing again keeps the same paths
+	again, err := Publish(source, target, PublishOptions{Dir: "public", ManifestPath: "assets.json"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if again["app.js"] != manifest["app.js"] {
+		t.Fatal("unexpected manifest:", again)
+	}
+}
+
+func TestStaticStorageFingerprintedUrl(t *testing.T) {
+	source := NewMemoryTestStorage(t)
+	target := NewMemoryTestStorage(t)
+
+	if err := source.Put("app.js", []byte("console.log('app')")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	manifest, err := Publish(source, target, PublishOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	s := newStaticTestStorage(t, http.StripPrefix("/assets/", http.FileServerFS(AsFS(target))), DEFAULT_MANIFEST_PATH).(*StaticStorage)
+
+	url, err := s.Url("app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !strings.HasSuffix(url, "/assets/"+manifest["app.js"]) {
+		t.Fatal("unexpected url:", url)
+	}
+
+	data, err := s.ReadFile("app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "console.log('app')" {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	// publishing with the static storage replaces its manifest
+	if err := source.Put("app.js", []byte("console.log('v2')")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	manifest, err = Publish(source, target, PublishOptions{Static: s})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err = s.ReadFile("app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "console.log('v2')" {
+		t.Fatal("expected the published content, got:", string(data))
+	}
+
+	// publishing elsewhere needs a refresh
+	if err := source.Put("app.js", []byte("console.log('v3')")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := Publish(source, target, PublishOptions{}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	url, err = s.Url("app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !strings.HasSuffix(url, "/assets/"+manifest["app.js"]) {
+		t.Fatal("expected the cached manifest, got:", url)
+	}
+
+	if err := s.RefreshManifest(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err = s.ReadFile("app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "console.log('v3')" {
+		t.Fatal("expected the refreshed content, got:", string(data))
+	}
+}
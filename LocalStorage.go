@@ -0,0 +1,553 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localTempSuffix ends the names of the temporary files of the writers,
+// which are left out of the listings, while written or after a crash
+const localTempSuffix = ".filesystem-tmp"
+
+// LocalStorage implements the StorageInterface for the local (OS) file system.
+// All paths are resolved relative to Disk.Root, and can not escape it.
+type LocalStorage struct {
+	disk Disk
+}
+
+var _ StorageInterface = (*LocalStorage)(nil)       // verify it extends the storage interface
+var _ StorageStreamInterface = (*LocalStorage)(nil) // verify it extends the storage stream interface
+var _ StorageRangeInterface = (*LocalStorage)(nil)  // verify it extends the storage range interface
+var _ StoragePageInterface = (*LocalStorage)(nil)   // verify it extends the storage page interface
+var _ StorageWalkInterface = (*LocalStorage)(nil)   // verify it extends the storage walk interface
+var _ StorageStatInterface = (*LocalStorage)(nil)   // verify it extends the storage stat interface
+var _ StoragePutInterface = (*LocalStorage)(nil)    // verify it extends the storage put interface
+var _ StorageCopyInterface = (*LocalStorage)(nil)   // verify it extends the storage copy interface
+
+// localDriver creates the storage of a DRIVER_LOCAL disk
+func localDriver(disk Disk) (StorageInterface, error) {
+	return &LocalStorage{disk: disk}, nil
+}
+
+func validateLocalDisk(disk Disk) error {
+	if disk.Root == "" {
+		return errors.New("root is required field")
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) Copy(originFile, targetFile string) error {
+	origin, err := os.Open(s.resolve(originFile))
+
+	if err != nil {
+		return osError("copy", originFile, err)
+	}
+
+	defer origin.Close()
+
+	info, err := origin.Stat()
+
+	if err != nil {
+		return osError("copy", originFile, err)
+	}
+
+	if info.IsDir() {
+		return newPathError("copy", originFile, ErrNotFile)
+	}
+
+	targetPath := s.resolve(targetFile)
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return osError("copy", targetFile, err)
+	}
+
+	target, err := os.Create(targetPath)
+
+	if err != nil {
+		return osError("copy", targetFile, err)
+	}
+
+	if _, err := io.Copy(target, origin); err != nil {
+		target.Close()
+		return osError("copy", targetFile, err)
+	}
+
+	return osError("copy", targetFile, target.Close())
+}
+
+// CopyDirectory copies the directory and all its contents, recursively
+func (s *LocalStorage) CopyDirectory(sourceDir, targetDir string) error {
+	return s.CopyDirectoryWithOptions(sourceDir, targetDir, CopyDirectoryOptions{})
+}
+
+// CopyDirectoryWithOptions copies the directory as CopyDirectory does, with the options
+func (s *LocalStorage) CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	return copyDirectory(s, sourceDir, targetDir, options, s.MakeDirectory, s.Copy)
+}
+
+func (s *LocalStorage) DeleteFile(filePaths []string) error {
+	for _, filePath := range filePaths {
+		fullPath := s.resolve(filePath)
+
+		if fullPath == s.resolve("") {
+			return newPathError("delete", filePath, errors.New("can not delete the root directory"))
+		}
+
+		if err := os.RemoveAll(fullPath); err != nil {
+			return osError("delete", filePath, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteDirectory deletes a directory, together with all its contents
+func (s *LocalStorage) DeleteDirectory(directory string) error {
+	fullPath := s.resolve(directory)
+
+	info, err := os.Stat(fullPath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return osError("delete", directory, err)
+	}
+
+	if !info.IsDir() {
+		return newPathError("delete", directory, ErrNotDirectory)
+	}
+
+	if fullPath == s.resolve("") {
+		return newPathError("delete", directory, errors.New("can not delete the root directory"))
+	}
+
+	return osError("delete", directory, os.RemoveAll(fullPath))
+}
+
+// Directories lists the sub-directories in the specified directory
+func (s *LocalStorage) Directories(dir string) ([]string, error) {
+	return s.list(dir, true)
+}
+
+// Files lists the files in the specified directory
+func (s *LocalStorage) Files(dir string) ([]string, error) {
+	return s.list(dir, false)
+}
+
+// ListPage lists a page of the entries of the directory
+func (s *LocalStorage) ListPage(dir string, cursor string, limit int) (Page, error) {
+	entries, err := os.ReadDir(s.resolve(dir))
+
+	if err != nil {
+		return Page{}, osError("list", dir, err)
+	}
+
+	dirPath := cleanPath(dir)
+	pageEntries := []pageEntry{}
+
+	for _, entry := range entries {
+		if isLocalTempFile(entry) {
+			continue
+		}
+
+		pageEntries = append(pageEntries, pageEntry{path: path.Join(dirPath, entry.Name()), isDir: entry.IsDir()})
+	}
+
+	return newPage(pageEntries, cursor, limit), nil
+}
+
+// Stat returns the metadata of a file or a directory
+func (s *LocalStorage) Stat(file string) (FileInfo, error) {
+	info, err := os.Stat(s.resolve(file))
+
+	if err != nil {
+		return FileInfo{}, osError("stat", file, err)
+	}
+
+	return s.fileInfo(cleanPath(file), info), nil
+}
+
+// List lists the files and sub-directories of the directory with their metadata
+func (s *LocalStorage) List(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(s.resolve(dir))
+
+	if err != nil {
+		return []FileInfo{}, osError("list", dir, err)
+	}
+
+	dirPath := cleanPath(dir)
+	infos := []FileInfo{}
+
+	for _, entry := range entries {
+		if isLocalTempFile(entry) {
+			continue
+		}
+
+		info, err := entry.Info()
+
+		// the entry may be removed, after it was listed
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return []FileInfo{}, osError("list", dir, err)
+		}
+
+		infos = append(infos, s.fileInfo(path.Join(dirPath, entry.Name()), info))
+	}
+
+	sortFileInfos(infos)
+
+	return infos, nil
+}
+
+// Walk calls fn for every file and directory under root, using filepath.WalkDir
+func (s *LocalStorage) Walk(root string, fn WalkFunc) error {
+	rootPath := s.resolve(root)
+	basePath := s.resolve("")
+
+	return filepath.WalkDir(rootPath, func(fullPath string, entry fs.DirEntry, err error) error {
+		if fullPath == rootPath {
+			if err == nil && !entry.IsDir() {
+				err = ErrNotDirectory
+			}
+
+			if err != nil {
+				return walkRootError(root, osError("walk", root, err), fn)
+			}
+
+			return nil
+		}
+
+		relativePath, relErr := filepath.Rel(basePath, fullPath)
+
+		if relErr != nil {
+			return relErr
+		}
+
+		filePath := filepath.ToSlash(relativePath)
+
+		if entry != nil && isLocalTempFile(entry) {
+			return nil
+		}
+
+		if err != nil {
+			return fn(filePath, entry != nil && entry.IsDir(), osError("walk", filePath, err))
+		}
+
+		return fn(filePath, entry.IsDir(), nil)
+	})
+}
+
+// AllFiles lists all the files in the directory and its sub-directories
+func (s *LocalStorage) AllFiles(dir string) ([]string, error) {
+	return walkAll(s.Walk, dir, false)
+}
+
+// AllDirectories lists all the sub-directories of the directory, recursively
+func (s *LocalStorage) AllDirectories(dir string) ([]string, error) {
+	return walkAll(s.Walk, dir, true)
+}
+
+func (s *LocalStorage) Exists(file string) (bool, error) {
+	_, err := os.Stat(s.resolve(file))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, osError("exists", file, err)
+	}
+
+	return true, nil
+}
+
+// MakeDirectory creates a directory, together with any missing parents
+func (s *LocalStorage) MakeDirectory(directory string) error {
+	return osError("mkdir", directory, os.MkdirAll(s.resolve(directory), 0755))
+}
+
+func (s *LocalStorage) Move(oldFile, newFile string) error {
+	newPath := s.resolve(newFile)
+
+	if _, err := os.Stat(s.resolve(oldFile)); err != nil {
+		return osError("move", oldFile, err)
+	}
+
+	// os.Rename replaces an existing file, which the other storages refuse
+	if _, err := os.Lstat(newPath); err == nil {
+		return newPathError("move", newFile, ErrAlreadyExists)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return osError("move", newFile, err)
+	}
+
+	return osError("move", newFile, os.Rename(s.resolve(oldFile), newPath))
+}
+
+// Put writes the content to the file, creating any missing parent
+// directories. An existing file is overwritten.
+func (s *LocalStorage) Put(filePath string, content []byte) error {
+	return s.PutWithOptions(filePath, content, PutOptions{})
+}
+
+// PutWithOptions writes the file as Put does. With IfNotExists, the
+// file is created exclusively, so concurrent creates do not race.
+func (s *LocalStorage) PutWithOptions(filePath string, content []byte, options PutOptions) error {
+	fullPath := s.resolve(filePath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return osError("put", filePath, err)
+	}
+
+	if !options.IfNotExists {
+		return osError("put", filePath, os.WriteFile(fullPath, content, 0644))
+	}
+
+	file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return osError("put", filePath, err)
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(fullPath)
+		return osError("put", filePath, err)
+	}
+
+	return osError("put", filePath, file.Close())
+}
+
+// PutStream writes the contents of the reader to a temporary file next
+// to the target, and renames it when done, so readers never see a
+// partially written file
+func (s *LocalStorage) PutStream(filePath string, reader io.Reader, size int64) error {
+	writer, err := s.OpenWriter(filePath)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.(*localWriter).abort()
+		return osError("put", filePath, err)
+	}
+
+	return writer.Close()
+}
+
+func (s *LocalStorage) OpenReader(file string) (io.ReadCloser, error) {
+	fullPath := s.resolve(file)
+
+	info, err := os.Stat(fullPath)
+
+	if err != nil {
+		return nil, osError("read", file, err)
+	}
+
+	if info.IsDir() {
+		return nil, newPathError("read", file, ErrNotFile)
+	}
+
+	reader, err := os.Open(fullPath)
+
+	if err != nil {
+		return nil, osError("read", file, err)
+	}
+
+	return reader, nil
+}
+
+// OpenWriter opens a temporary file next to the target for writing,
+// which replaces the target when the writer is closed. The temporary
+// file is hidden from the listings.
+func (s *LocalStorage) OpenWriter(file string) (io.WriteCloser, error) {
+	fullPath := s.resolve(file)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, osError("put", file, err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*"+localTempSuffix)
+
+	if err != nil {
+		return nil, osError("put", file, err)
+	}
+
+	return &localWriter{file: temp, path: file, target: fullPath}, nil
+}
+
+func (s *LocalStorage) ReadFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(s.resolve(file))
+
+	if err != nil {
+		return nil, osError("read", file, err)
+	}
+
+	return data, nil
+}
+
+// ReadRange reads length bytes of the file, starting at offset
+func (s *LocalStorage) ReadRange(file string, offset, length int64) ([]byte, error) {
+	reader, err := s.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	osFile := reader.(*os.File)
+
+	info, err := osFile.Stat()
+
+	if err != nil {
+		return nil, osError("read", file, err)
+	}
+
+	start, end, err := rangeBounds(offset, length, info.Size())
+
+	if err != nil {
+		return nil, newPathError("read", file, err)
+	}
+
+	data := make([]byte, end-start)
+	n, err := osFile.ReadAt(data, start)
+
+	if err != nil && err != io.EOF {
+		return nil, osError("read", file, err)
+	}
+
+	return data[:n], nil
+}
+
+// Open opens the file for random access reading, the returned
+// reader is an *os.File
+func (s *LocalStorage) Open(file string) (io.ReadSeekCloser, error) {
+	reader, err := s.OpenReader(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.(*os.File), nil
+}
+
+func (s *LocalStorage) Size(file string) (int64, error) {
+	info, err := os.Stat(s.resolve(file))
+
+	if err != nil {
+		return -1, osError("size", file, err)
+	}
+
+	if info.IsDir() {
+		return -1, newPathError("size", file, ErrNotFile)
+	}
+
+	return info.Size(), nil
+}
+
+func (s *LocalStorage) LastModified(file string) (time.Time, error) {
+	info, err := os.Stat(s.resolve(file))
+
+	if err != nil {
+		return time.Time{}, osError("lastmodified", file, err)
+	}
+
+	return info.ModTime(), nil
+}
+
+func (s *LocalStorage) Url(file string) (string, error) {
+	return joinUrl(s.disk.Url, cleanPath(file)), nil
+}
+
+func (s *LocalStorage) fileInfo(filePath string, info os.FileInfo) FileInfo {
+	if info.IsDir() {
+		return newFileInfo(diskVisibility(s.disk), filePath, true, 0, info.ModTime())
+	}
+
+	return newFileInfo(diskVisibility(s.disk), filePath, false, info.Size(), info.ModTime())
+}
+
+// localWriter writes to a temporary file, which is renamed
+// to the target path when closed
+type localWriter struct {
+	file   *os.File
+	path   string
+	target string
+}
+
+func (w *localWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+func (w *localWriter) Close() error {
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return osError("put", w.path, err)
+	}
+
+	if err := os.Chmod(w.file.Name(), 0644); err != nil {
+		os.Remove(w.file.Name())
+		return osError("put", w.path, err)
+	}
+
+	if err := os.Rename(w.file.Name(), w.target); err != nil {
+		os.Remove(w.file.Name())
+		return osError("put", w.path, err)
+	}
+
+	return nil
+}
+
+// abort closes and removes the temporary file, leaving the target untouched
+func (w *localWriter) abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// list lists the entries of a directory, either only the sub-directories
+// or only the files, as paths relative to the root
+func (s *LocalStorage) list(dir string, directories bool) ([]string, error) {
+	entries, err := os.ReadDir(s.resolve(dir))
+
+	if err != nil {
+		return []string{}, osError("list", dir, err)
+	}
+
+	dirPath := cleanPath(dir)
+	paths := []string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() != directories || isLocalTempFile(entry) {
+			continue
+		}
+
+		paths = append(paths, path.Join(dirPath, entry.Name()))
+	}
+
+	return paths, nil
+}
+
+// isLocalTempFile checks if the entry is the temporary file of a writer
+func isLocalTempFile(entry fs.DirEntry) bool {
+	return !entry.IsDir() && strings.HasPrefix(entry.Name(), ".") && strings.HasSuffix(entry.Name(), localTempSuffix)
+}
+
+// resolve converts a storage path to an OS path inside the root directory
+func (s *LocalStorage) resolve(filePath string) string {
+	return filepath.Join(s.disk.Root, filepath.FromSlash(cleanPath(filePath)))
+}
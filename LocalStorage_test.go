@@ -0,0 +1,111 @@
+package filesystem
+
+import (
+	"testing"
+)
+
+// NewLocalTestStorage creates a local storage in a temporary directory,
+// i.e. for the conformance tests in the filesystem_test package
+func NewLocalTestStorage(t *testing.T) StorageInterface {
+	return newTestStorage(t, Disk{
+		DiskName: "local",
+		Driver:   DRIVER_LOCAL,
+		Url:      "http://localhost/media",
+		Root:     t.TempDir(),
+	})
+}
+
+func TestLocalStorageRootRequired(t *testing.T) {
+	_, err := NewStorage(Disk{
+		Driver: DRIVER_LOCAL,
+		Url:    "http://localhost/media",
+	})
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestLocalStorageConfinedToRoot(t *testing.T) {
+	s := NewLocalTestStorage(t)
+
+	if err := s.Put("../../escape.txt", []byte("test")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	exists, err := s.Exists("escape.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !exists {
+		t.Fatal("expected file to be written inside the root")
+	}
+
+	url, err := s.Url("../escape.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if url != "http://localhost/media/escape.txt" {
+		t.Fatal("unexpected url:", url)
+	}
+}
+
+func TestLocalStorageWriterTempFileHidden(t *testing.T) {
+	s := NewLocalTestStorage(t).(*LocalStorage)
+
+	writer, err := s.OpenWriter("dir/file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	defer writer.Close()
+
+	if _, err := writer.Write([]byte("test")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	files, err := s.Files("dir")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(files) != 0 {
+		t.Fatal("expected the temporary file to be hidden, got:", files)
+	}
+
+	allFiles, err := s.AllFiles("")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(allFiles) != 0 {
+		t.Fatal("expected the temporary file to be hidden, got:", allFiles)
+	}
+
+	infos, err := s.List("dir")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(infos) != 0 {
+		t.Fatal("expected the temporary file to be hidden, got:", infos)
+	}
+
+	page, err := s.ListPage("dir", "", 10)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(page.Files) != 0 {
+		t.Fatal("expected the temporary file to be hidden, got:", page.Files)
+	}
+}
@@ -0,0 +1,147 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrDiskNotRegistered is returned by the Manager for unknown disk names
+var ErrDiskNotRegistered = errors.New("disk not registered")
+
+// Manager keeps the disks of the application by name. The storages are
+// created with NewStorage on first use, and reused afterwards.
+// It is safe for concurrent use.
+//
+//	manager, err := filesystem.NewManager(mediaDisk, cdnDisk)
+//	storage, err := manager.Disk(filesystem.CDN)
+//	storage, err := manager.Default()
+type Manager struct {
+	mu          sync.Mutex
+	disks       map[string]Disk
+	storages    map[string]StorageInterface
+	generations map[string]int // the registrations of each disk name
+	defaultDisk string
+}
+
+// NewManager creates a manager with the disks registered,
+// and DEFAULT as the name of the default disk
+func NewManager(disks ...Disk) (*Manager, error) {
+	manager := &Manager{
+		disks:       map[string]Disk{},
+		storages:    map[string]StorageInterface{},
+		generations: map[string]int{},
+		defaultDisk: DEFAULT,
+	}
+
+	for _, disk := range disks {
+		if err := manager.Register(disk); err != nil {
+			return nil, err
+		}
+	}
+
+	return manager, nil
+}
+
+// Register adds the disk under its DiskName. Registering a name
+// again replaces the disk, and the storage created for it.
+func (m *Manager) Register(disk Disk) error {
+	if disk.DiskName == "" {
+		return errors.New("disk name is required field")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.disks[disk.DiskName] = disk
+	m.generations[disk.DiskName]++
+	delete(m.storages, disk.DiskName)
+
+	return nil
+}
+
+// SetDefault sets the name of the disk returned by Default
+func (m *Manager) SetDefault(diskName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.defaultDisk = diskName
+}
+
+// Disk returns the storage of the named disk, creating it on first use.
+// A storage, which fails to be created, is attempted again on the next call.
+func (m *Manager) Disk(diskName string) (StorageInterface, error) {
+	return m.storage(diskName)
+}
+
+// Default returns the storage of the default disk, DEFAULT unless changed with SetDefault
+func (m *Manager) Default() (StorageInterface, error) {
+	m.mu.Lock()
+	diskName := m.defaultDisk
+	m.mu.Unlock()
+
+	return m.storage(diskName)
+}
+
+// Names returns the names of the registered disks, sorted
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := []string{}
+
+	for name := range m.disks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// storage returns the cached storage of the disk, or creates it. The
+// storage is created without holding the lock, so a slow disk does not
+// block the others. When two calls create the same storage, the first
+// one stored is kept. A storage created for a disk, which was registered
+// again meanwhile, is not stored, and created again for the new disk.
+func (m *Manager) storage(diskName string) (StorageInterface, error) {
+	for {
+		m.mu.Lock()
+		storage, cached := m.storages[diskName]
+		disk, exists := m.disks[diskName]
+		generation := m.generations[diskName]
+		m.mu.Unlock()
+
+		if cached {
+			return storage, nil
+		}
+
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrDiskNotRegistered, diskName)
+		}
+
+		storage, err := NewStorage(disk)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+
+		if m.generations[diskName] != generation {
+			m.mu.Unlock()
+			continue
+		}
+
+		if existing, cached := m.storages[diskName]; cached {
+			storage = existing
+		} else {
+			m.storages[diskName] = storage
+		}
+
+		m.mu.Unlock()
+
+		return storage, nil
+	}
+}
@@ -0,0 +1,185 @@
+package filesystem
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func managerInit(t *testing.T) *Manager {
+	manager, err := NewManager(
+		Disk{DiskName: DEFAULT, Driver: DRIVER_MEMORY, Url: "http://localhost/media"},
+		Disk{DiskName: "broken", Driver: DRIVER_LOCAL, Url: "http://localhost/files"},
+	)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return manager
+}
+
+func TestManagerDisk(t *testing.T) {
+	manager := managerInit(t)
+
+	storage, err := manager.Disk(DEFAULT)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the storage is cached, so the same one is returned
+	defaultStorage, err := manager.Default()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if defaultStorage != storage {
+		t.Fatal("expected the same storage")
+	}
+
+	if strings.Join(manager.Names(), ",") != "broken,default" {
+		t.Fatal("unexpected names:", manager.Names())
+	}
+}
+
+func TestManagerErrors(t *testing.T) {
+	manager := managerInit(t)
+
+	if _, err := manager.Disk("missing"); !errors.Is(err, ErrDiskNotRegistered) {
+		t.Fatal("expected ErrDiskNotRegistered, got:", err)
+	}
+
+	if _, err := manager.Disk("broken"); err == nil || err.Error() != "root is required field" {
+		t.Fatal("expected the root error, got:", err)
+	}
+
+	manager.SetDefault(CDN)
+
+	if _, err := manager.Default(); !errors.Is(err, ErrDiskNotRegistered) {
+		t.Fatal("expected ErrDiskNotRegistered, got:", err)
+	}
+
+	if err := manager.Register(Disk{Driver: DRIVER_MEMORY}); err == nil {
+		t.Fatal("expected an error for a disk without name")
+	}
+
+	if _, err := NewManager(Disk{Driver: DRIVER_MEMORY}); err == nil {
+		t.Fatal("expected an error for a disk without name")
+	}
+}
+
+func TestManagerRegisterReplaces(t *testing.T) {
+	manager := managerInit(t)
+
+	storage, err := manager.Default()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := manager.Register(Disk{DiskName: DEFAULT, Driver: DRIVER_MEMORY, Url: "http://localhost/other"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	replaced, err := manager.Default()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if replaced == storage {
+		t.Fatal("expected a new storage")
+	}
+
+	url, err := replaced.Url("test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if url != "http://localhost/other/test.txt" {
+		t.Fatal("unexpected url:", url)
+	}
+}
+
+func TestManagerConcurrent(t *testing.T) {
+	manager := managerInit(t)
+
+	storages := make([]StorageInterface, 20)
+	wg := sync.WaitGroup{}
+
+	for i := range storages {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			storages[i], _ = manager.Default()
+		}()
+	}
+
+	wg.Wait()
+
+	for _, storage := range storages {
+		if storage == nil || storage != storages[0] {
+			t.Fatal("expected the same storage for all the goroutines")
+		}
+	}
+}
+
+func TestManagerSlowDiskDoesNotBlock(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	RegisterDriver("test-slow", func(disk Disk) (StorageInterface, error) {
+		close(started)
+		<-release
+		return memoryDriver(disk)
+	})
+
+	t.Cleanup(func() { // so the test can run again, as with -count
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		delete(drivers, "test-slow")
+	})
+
+	manager := managerInit(t)
+
+	if err := manager.Register(Disk{DiskName: "slow", Driver: "test-slow", Url: "http://localhost/slow"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	slow := make(chan error)
+
+	go func() {
+		_, err := manager.Disk("slow")
+		slow <- err
+	}()
+
+	<-started
+
+	done := make(chan error)
+
+	go func() {
+		_, err := manager.Default()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the default disk not to wait for the slow disk")
+	}
+
+	close(release)
+
+	if err := <-slow; err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,93 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Manifest maps the paths of the files of a static disk to the paths
+// they are served from. The two are the same, unless the files are
+// published under different names.
+//
+// In JSON, the manifest is either an object of paths (webpack style),
+// an object of entries with the served path in "file" (Vite style), or
+// an array of paths (for manifests which only list the files):
+//
+//	{"app.js": "app.3f9a1c.js", "css/site.css": "css/site.8b2e4d.css"}
+//	{"app.js": {"file": "assets/app.3f9a1c.js"}}
+//	["app.js", "css/site.css"]
+type Manifest map[string]string
+
+// LoadManifest reads and parses the JSON manifest from the storage
+func LoadManifest(storage StorageInterface, manifestPath string) (Manifest, error) {
+	data, err := storage.ReadFile(manifestPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, newPathError("manifest", manifestPath, err)
+	}
+
+	return manifest, nil
+}
+
+// Path returns the path the file is served from, the
+// path itself when it is not in the manifest
+func (m Manifest) Path(filePath string) string {
+	if servedPath, exists := m[cleanPath(filePath)]; exists {
+		return servedPath
+	}
+
+	if servedPath, exists := m[filePath]; exists {
+		return servedPath
+	}
+
+	return filePath
+}
+
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	paths := []string{}
+
+	if err := json.Unmarshal(data, &paths); err == nil {
+		*m = Manifest{}
+
+		for _, filePath := range paths {
+			(*m)[filePath] = filePath
+		}
+
+		return nil
+	}
+
+	entries := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return errors.New("manifest must be an object or an array of paths")
+	}
+
+	*m = Manifest{}
+
+	for filePath, value := range entries {
+		servedPath := ""
+
+		if err := json.Unmarshal(value, &servedPath); err == nil {
+			(*m)[filePath] = servedPath
+			continue
+		}
+
+		entry := struct {
+			File string `json:"file"`
+		}{}
+
+		if err := json.Unmarshal(value, &entry); err != nil || entry.File == "" {
+			return errors.New("manifest entry " + filePath + " must be a path, or an object with a file")
+		}
+
+		(*m)[filePath] = entry.File
+	}
+
+	return nil
+}
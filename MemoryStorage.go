@@ -0,0 +1,578 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage implements the StorageInterface fully in memory.
+// It is meant for tests and ephemeral workloads, the contents are
+// lost when the process exits. It is safe for concurrent use.
+type MemoryStorage struct {
+	disk    Disk
+	mu      sync.RWMutex
+	entries map[string]*memoryEntry // keyed by the clean path, the root is implicit
+}
+
+// memoryEntry is a single file or directory in the memory storage
+type memoryEntry struct {
+	isDir    bool
+	content  []byte
+	modified time.Time
+}
+
+var _ StorageInterface = (*MemoryStorage)(nil)       // verify it extends the storage interface
+var _ StorageStreamInterface = (*MemoryStorage)(nil) // verify it extends the storage stream interface
+var _ StorageRangeInterface = (*MemoryStorage)(nil)  // verify it extends the storage range interface
+var _ StoragePageInterface = (*MemoryStorage)(nil)   // verify it extends the storage page interface
+var _ StorageWalkInterface = (*MemoryStorage)(nil)   // verify it extends the storage walk interface
+var _ StorageStatInterface = (*MemoryStorage)(nil)   // verify it extends the storage stat interface
+var _ StoragePutInterface = (*MemoryStorage)(nil)    // verify it extends the storage put interface
+var _ StorageCopyInterface = (*MemoryStorage)(nil)   // verify it extends the storage copy interface
+
+// memoryDriver creates the storage of a DRIVER_MEMORY disk
+func memoryDriver(disk Disk) (StorageInterface, error) {
+	return &MemoryStorage{disk: disk}, nil
+}
+
+func (s *MemoryStorage) Copy(originFile, targetFile string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	origin, err := s.file(cleanPath(originFile))
+
+	if err != nil {
+		return newPathError("copy", originFile, err)
+	}
+
+	return newPathError("copy", targetFile, s.write(cleanPath(targetFile), origin.content))
+}
+
+// CopyDirectory copies the directory and all its contents, recursively
+func (s *MemoryStorage) CopyDirectory(sourceDir, targetDir string) error {
+	return s.CopyDirectoryWithOptions(sourceDir, targetDir, CopyDirectoryOptions{})
+}
+
+// CopyDirectoryWithOptions copies the directory as CopyDirectory does, with the options
+func (s *MemoryStorage) CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	return copyDirectory(s, sourceDir, targetDir, options, s.MakeDirectory, s.Copy)
+}
+
+func (s *MemoryStorage) DeleteFile(filePaths []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, filePath := range filePaths {
+		filePath = cleanPath(filePath)
+
+		if filePath == "" {
+			return newPathError("delete", filePath, errors.New("can not delete the root directory"))
+		}
+
+		s.remove(filePath)
+	}
+
+	return nil
+}
+
+// DeleteDirectory deletes a directory, together with all its contents
+func (s *MemoryStorage) DeleteDirectory(directory string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	directory = cleanPath(directory)
+
+	if directory == "" {
+		return newPathError("delete", directory, errors.New("can not delete the root directory"))
+	}
+
+	entry, exists := s.entries[directory]
+
+	if !exists {
+		return nil
+	}
+
+	if !entry.isDir {
+		return newPathError("delete", directory, ErrNotDirectory)
+	}
+
+	s.remove(directory)
+
+	return nil
+}
+
+// Directories lists the sub-directories in the specified directory
+func (s *MemoryStorage) Directories(dir string) ([]string, error) {
+	return s.list(dir, true)
+}
+
+// Files lists the files in the specified directory
+func (s *MemoryStorage) Files(dir string) ([]string, error) {
+	return s.list(dir, false)
+}
+
+// ListPage lists a page of the entries of the directory
+func (s *MemoryStorage) ListPage(dir string, cursor string, limit int) (Page, error) {
+	directories, err := s.list(dir, true)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	files, err := s.list(dir, false)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	return newPage(append(toPageEntries(directories, true), toPageEntries(files, false)...), cursor, limit), nil
+}
+
+// Stat returns the metadata of a file or a directory
+func (s *MemoryStorage) Stat(filePath string) (FileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entryPath := cleanPath(filePath)
+
+	if entryPath == "" {
+		return newFileInfo(diskVisibility(s.disk), "", true, 0, time.Time{}), nil
+	}
+
+	entry, exists := s.entries[entryPath]
+
+	if !exists {
+		return FileInfo{}, newPathError("stat", filePath, ErrNotFound)
+	}
+
+	return s.fileInfo(entryPath, entry), nil
+}
+
+// List lists the files and sub-directories of the directory with their metadata
+func (s *MemoryStorage) List(dir string) ([]FileInfo, error) {
+	directories, err := s.list(dir, true)
+
+	if err != nil {
+		return []FileInfo{}, err
+	}
+
+	files, err := s.list(dir, false)
+
+	if err != nil {
+		return []FileInfo{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := []FileInfo{}
+
+	for _, entryPath := range append(directories, files...) {
+		// the entry may be removed, after it was listed
+		if entry, exists := s.entries[entryPath]; exists {
+			infos = append(infos, s.fileInfo(entryPath, entry))
+		}
+	}
+
+	sortFileInfos(infos)
+
+	return infos, nil
+}
+
+// Walk calls fn for every file and directory under root
+func (s *MemoryStorage) Walk(root string, fn WalkFunc) error {
+	entries, err := s.walkEntries(root)
+
+	if err != nil {
+		return walkRootError(root, err, fn)
+	}
+
+	return walkEntries(entries, fn)
+}
+
+// AllFiles lists all the files in the directory and its sub-directories
+func (s *MemoryStorage) AllFiles(dir string) ([]string, error) {
+	return walkAll(s.Walk, dir, false)
+}
+
+// AllDirectories lists all the sub-directories of the directory, recursively
+func (s *MemoryStorage) AllDirectories(dir string) ([]string, error) {
+	return walkAll(s.Walk, dir, true)
+}
+
+func (s *MemoryStorage) Exists(file string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file = cleanPath(file)
+
+	if file == "" {
+		return true, nil
+	}
+
+	_, exists := s.entries[file]
+
+	return exists, nil
+}
+
+// MakeDirectory creates a directory, together with any missing parents
+func (s *MemoryStorage) MakeDirectory(directory string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = map[string]*memoryEntry{}
+	}
+
+	return newPathError("mkdir", directory, s.mkdirAll(cleanPath(directory), time.Now()))
+}
+
+// Move moves (renames) a file or a directory, together with all its contents
+func (s *MemoryStorage) Move(oldFile, newFile string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldFile = cleanPath(oldFile)
+	newFile = cleanPath(newFile)
+
+	if oldFile == "" || newFile == "" {
+		return newPathError("move", oldFile, errors.New("can not move the root directory"))
+	}
+
+	if oldFile == newFile {
+		return newPathError("move", oldFile, errors.New("origin and target paths are the same"))
+	}
+
+	entry, exists := s.entries[oldFile]
+
+	if !exists {
+		return newPathError("move", oldFile, ErrNotFound)
+	}
+
+	if entry.isDir && strings.HasPrefix(newFile, oldFile+PATH_SEPARATOR) {
+		return newPathError("move", oldFile, errors.New("can not move a directory inside itself"))
+	}
+
+	if _, exists := s.entries[newFile]; exists {
+		return newPathError("move", newFile, ErrAlreadyExists)
+	}
+
+	if err := s.mkdirAll(s.parent(newFile), time.Now()); err != nil {
+		return newPathError("move", newFile, err)
+	}
+
+	moved := map[string]*memoryEntry{}
+
+	for entryPath, entry := range s.entries {
+		if entryPath == oldFile || strings.HasPrefix(entryPath, oldFile+PATH_SEPARATOR) {
+			moved[newFile+strings.TrimPrefix(entryPath, oldFile)] = entry
+		}
+	}
+
+	s.remove(oldFile)
+
+	for entryPath, entry := range moved {
+		s.entries[entryPath] = entry
+	}
+
+	return nil
+}
+
+// Put writes the content to the file, creating any missing parent
+// directories. An existing file is overwritten.
+func (s *MemoryStorage) Put(filePath string, content []byte) error {
+	return s.PutWithOptions(filePath, content, PutOptions{})
+}
+
+// PutWithOptions writes the file, with IfNotExists only creating it
+func (s *MemoryStorage) PutWithOptions(filePath string, content []byte, options PutOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.entries[cleanPath(filePath)]; exists && options.IfNotExists {
+		return newPathError("put", filePath, ErrAlreadyExists)
+	}
+
+	return newPathError("put", filePath, s.write(cleanPath(filePath), content))
+}
+
+// PutStream writes the contents of the reader to the file
+func (s *MemoryStorage) PutStream(filePath string, reader io.Reader, size int64) error {
+	content, err := io.ReadAll(reader)
+
+	if err != nil {
+		return newPathError("put", filePath, err)
+	}
+
+	return s.Put(filePath, content)
+}
+
+// OpenReader opens the file for reading. The reader sees the contents
+// of the file at the time it was opened.
+func (s *MemoryStorage) OpenReader(file string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, err := s.file(cleanPath(file))
+
+	if err != nil {
+		return nil, newPathError("read", file, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(entry.content)), nil
+}
+
+// OpenWriter opens the file for writing, the file is stored when the writer is closed
+func (s *MemoryStorage) OpenWriter(file string) (io.WriteCloser, error) {
+	return &bufferWriter{flush: func(content []byte) error {
+		return s.Put(file, content)
+	}}, nil
+}
+
+func (s *MemoryStorage) ReadFile(file string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, err := s.file(cleanPath(file))
+
+	if err != nil {
+		return nil, newPathError("read", file, err)
+	}
+
+	return append([]byte{}, entry.content...), nil
+}
+
+// ReadRange reads length bytes of the file, starting at offset
+func (s *MemoryStorage) ReadRange(file string, offset, length int64) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, err := s.file(cleanPath(file))
+
+	if err != nil {
+		return nil, newPathError("read", file, err)
+	}
+
+	start, end, err := rangeBounds(offset, length, int64(len(entry.content)))
+
+	if err != nil {
+		return nil, newPathError("read", file, err)
+	}
+
+	return append([]byte{}, entry.content[start:end]...), nil
+}
+
+// Open opens the file for random access reading. The reader sees the
+// contents of the file at the time it was opened.
+func (s *MemoryStorage) Open(file string) (io.ReadSeekCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, err := s.file(cleanPath(file))
+
+	if err != nil {
+		return nil, newPathError("read", file, err)
+	}
+
+	return bytesReadSeekCloser{bytes.NewReader(entry.content)}, nil
+}
+
+func (s *MemoryStorage) Size(file string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, err := s.file(cleanPath(file))
+
+	if err != nil {
+		return -1, newPathError("size", file, err)
+	}
+
+	return int64(len(entry.content)), nil
+}
+
+func (s *MemoryStorage) LastModified(file string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, exists := s.entries[cleanPath(file)]
+
+	if !exists {
+		return time.Time{}, newPathError("lastmodified", file, ErrNotFound)
+	}
+
+	return entry.modified, nil
+}
+
+func (s *MemoryStorage) Url(file string) (string, error) {
+	return joinUrl(s.disk.Url, cleanPath(file)), nil
+}
+
+// file returns the file entry at the specified path, the caller must hold the lock
+func (s *MemoryStorage) file(filePath string) (*memoryEntry, error) {
+	entry, exists := s.entries[filePath]
+
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	if entry.isDir {
+		return nil, ErrNotFile
+	}
+
+	return entry, nil
+}
+
+// fileInfo returns the metadata of the entry, the caller must hold the lock
+func (s *MemoryStorage) fileInfo(entryPath string, entry *memoryEntry) FileInfo {
+	return newFileInfo(diskVisibility(s.disk), entryPath, entry.isDir, int64(len(entry.content)), entry.modified)
+}
+
+// list lists the entries of a directory, either only the sub-directories
+// or only the files, sorted by path
+func (s *MemoryStorage) list(dir string, directories bool) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dirPath := cleanPath(dir)
+
+	if dirPath != "" {
+		entry, exists := s.entries[dirPath]
+
+		if !exists {
+			return []string{}, newPathError("list", dir, ErrNotFound)
+		}
+
+		if !entry.isDir {
+			return []string{}, newPathError("list", dir, ErrNotDirectory)
+		}
+	}
+
+	paths := []string{}
+
+	for entryPath, entry := range s.entries {
+		if entry.isDir != directories {
+			continue
+		}
+
+		if s.parent(entryPath) != dirPath {
+			continue
+		}
+
+		paths = append(paths, entryPath)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// walkEntries collects all the entries under the root. The lock is
+// released before walking, so fn can use the storage.
+func (s *MemoryStorage) walkEntries(root string) ([]pageEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rootPath := cleanPath(root)
+	prefix := ""
+
+	if rootPath != "" {
+		entry, exists := s.entries[rootPath]
+
+		if !exists {
+			return nil, newPathError("walk", root, ErrNotFound)
+		}
+
+		if !entry.isDir {
+			return nil, newPathError("walk", root, ErrNotDirectory)
+		}
+
+		prefix = rootPath + "/"
+	}
+
+	entries := []pageEntry{}
+
+	for entryPath, entry := range s.entries {
+		if strings.HasPrefix(entryPath, prefix) {
+			entries = append(entries, pageEntry{path: entryPath, isDir: entry.isDir})
+		}
+	}
+
+	return entries, nil
+}
+
+// mkdirAll creates the directory and any missing parents, the caller must hold the lock
+func (s *MemoryStorage) mkdirAll(directory string, modified time.Time) error {
+	if directory == "" {
+		return nil
+	}
+
+	if entry, exists := s.entries[directory]; exists {
+		if !entry.isDir {
+			return ErrNotDirectory
+		}
+
+		return nil
+	}
+
+	if err := s.mkdirAll(s.parent(directory), modified); err != nil {
+		return err
+	}
+
+	s.entries[directory] = &memoryEntry{isDir: true, modified: modified}
+
+	return nil
+}
+
+// parent returns the clean path of the parent directory
+func (s *MemoryStorage) parent(filePath string) string {
+	parent := path.Dir(filePath)
+
+	if parent == "." {
+		return ""
+	}
+
+	return parent
+}
+
+// remove removes the entry and all its descendants, the caller must hold the lock
+func (s *MemoryStorage) remove(filePath string) {
+	for entryPath := range s.entries {
+		if entryPath == filePath || strings.HasPrefix(entryPath, filePath+PATH_SEPARATOR) {
+			delete(s.entries, entryPath)
+		}
+	}
+}
+
+// write stores a copy of the content, the caller must hold the lock
+func (s *MemoryStorage) write(filePath string, content []byte) error {
+	if filePath == "" {
+		return ErrNotFile
+	}
+
+	if entry, exists := s.entries[filePath]; exists && entry.isDir {
+		return ErrNotFile
+	}
+
+	if s.entries == nil {
+		s.entries = map[string]*memoryEntry{}
+	}
+
+	now := time.Now()
+
+	if err := s.mkdirAll(s.parent(filePath), now); err != nil {
+		return err
+	}
+
+	s.entries[filePath] = &memoryEntry{
+		content:  append([]byte{}, content...),
+		modified: now,
+	}
+
+	return nil
+}
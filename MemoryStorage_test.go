@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// NewMemoryTestStorage creates an empty memory storage,
+// i.e. for the conformance tests in the filesystem_test package
+func NewMemoryTestStorage(t *testing.T) StorageInterface {
+	return newTestStorage(t, Disk{
+		DiskName: "memory",
+		Driver:   DRIVER_MEMORY,
+		Url:      "http://localhost/media",
+	})
+}
+
+func TestMemoryStorageMoveDirectory(t *testing.T) {
+	s := NewMemoryTestStorage(t)
+
+	if err := s.Put("dir/a.txt", []byte("a")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.Put("dir/b.txt", []byte("b")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.Move("dir", "moved"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	files, err := s.Files("moved")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(files) != 2 || files[0] != "moved/a.txt" || files[1] != "moved/b.txt" {
+		t.Fatal("unexpected files:", files)
+	}
+
+	if _, err := s.Files("dir"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestMemoryStorageConcurrentUse(t *testing.T) {
+	s := NewMemoryTestStorage(t)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			filePath := "dir/" + strconv.Itoa(i) + ".txt"
+
+			if err := s.Put(filePath, []byte("test")); err != nil {
+				t.Error("unexpected error:", err)
+			}
+
+			if _, err := s.ReadFile(filePath); err != nil {
+				t.Error("unexpected error:", err)
+			}
+
+			if _, err := s.Files("dir"); err != nil {
+				t.Error("unexpected error:", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	files, err := s.Files("dir")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(files) != 50 {
+		t.Fatal("unexpected number of files:", len(files))
+	}
+}
+
+func TestMemoryStorageWalkMissingRoot(t *testing.T) {
+	s := NewMemoryTestStorage(t).(StorageWalkInterface)
+
+	calls := 0
+
+	err := s.Walk("missing", func(filePath string, isDir bool, err error) error {
+		calls++
+
+		if filePath != "missing" || !errors.Is(err, ErrNotFound) {
+			t.Fatal("expected ErrNotFound for the root, found:", filePath, err)
+		}
+
+		return err
+	})
+
+	if calls != 1 {
+		t.Fatal("expected a single call for the missing root, found:", calls)
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+}
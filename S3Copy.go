@@ -0,0 +1,162 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const s3CopyConcurrency = 4
+
+// CopyDirectory copies the directory and all its contents, recursively,
+// with server side CopyObject requests
+func (s *S3Storage) CopyDirectory(sourceDir, targetDir string) error {
+	return s.CopyDirectoryWithOptionsContext(context.Background(), sourceDir, targetDir, CopyDirectoryOptions{})
+}
+
+func (s *S3Storage) CopyDirectoryContext(ctx context.Context, sourceDir, targetDir string) error {
+	return s.CopyDirectoryWithOptionsContext(ctx, sourceDir, targetDir, CopyDirectoryOptions{})
+}
+
+// CopyDirectoryWithOptions copies the directory as CopyDirectory does, with the options
+func (s *S3Storage) CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	return s.CopyDirectoryWithOptionsContext(context.Background(), sourceDir, targetDir, options)
+}
+
+// CopyDirectoryWithOptionsContext copies the objects under the source
+// directory, with at most s3CopyConcurrency requests in flight. After a
+// failed request no more copies are started, and the failures of the
+// requests in flight are collected.
+func (s *S3Storage) CopyDirectoryWithOptionsContext(ctx context.Context, sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	sourceDir, targetDir, err := checkCopyDirectory(sourceDir, targetDir, options)
+
+	if err != nil {
+		return err
+	}
+
+	sourcePrefix := s.toValidS3DirPath(sourceDir)
+	targetPrefix := s.toValidS3DirPath(targetDir)
+
+	sourceKeys, err := s.listKeys(ctx, sourcePrefix)
+
+	if err != nil {
+		return s3Error("copy", sourceDir, err)
+	}
+
+	if len(sourceKeys) == 0 {
+		return newPathError("copy", sourceDir, ErrNotFound)
+	}
+
+	targetKeys, err := s.listKeys(ctx, targetPrefix)
+
+	if err != nil {
+		return s3Error("copy", targetDir, err)
+	}
+
+	existing := make(map[string]bool, len(targetKeys))
+
+	for _, key := range targetKeys {
+		existing[key] = true
+	}
+
+	copies := []copyEntry{}
+
+	for _, key := range sourceKeys {
+		entry := copyEntry{
+			source: key,
+			target: targetPrefix + strings.TrimPrefix(key, sourcePrefix),
+			isDir:  strings.HasSuffix(key, "/"), // a directory marker
+		}
+
+		if entry.target == "" { // the marker of the source, copied to the root
+			continue
+		}
+
+		if existing[entry.target] && (entry.isDir || options.SkipExisting) {
+			continue
+		}
+
+		if existing[entry.target] && !options.Overwrite {
+			return newPathError("copy", entry.target, ErrAlreadyExists)
+		}
+
+		copies = append(copies, entry)
+	}
+
+	errs := []error{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, s3CopyConcurrency)
+
+	for _, entry := range copies {
+		semaphore <- struct{}{}
+
+		mu.Lock()
+		failed := len(errs) > 0
+		mu.Unlock()
+
+		if failed {
+			<-semaphore
+			break
+		}
+
+		wg.Add(1)
+
+		go func(entry copyEntry) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			var err error
+
+			if entry.isDir {
+				err = s.MakeDirectoryContext(ctx, entry.target)
+			} else {
+				err = s.CopyContext(ctx, entry.source, entry.target)
+			}
+
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(entry)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// listKeys lists all the keys under the prefix, including the directory markers
+func (s *S3Storage) listKeys(ctx context.Context, prefix string) ([]string, error) {
+	s3Client, err := s.client()
+
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.disk.Bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	keys := []string{}
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+
+	return keys, nil
+}
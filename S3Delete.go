@@ -0,0 +1,100 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const s3MaxDeleteObjects = 1000 // maximum number of keys per DeleteObjects request
+const s3DeleteConcurrency = 4
+
+// s3Deleter deletes objects in batches of up to 1000 keys, with at most
+// s3DeleteConcurrency batches in flight. The failures of all batches,
+// including the per-key failures reported by S3, are collected.
+type s3Deleter struct {
+	storage   *S3Storage
+	s3Client  *s3.Client
+	ctx       context.Context
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	errs      []error
+}
+
+func (s *S3Storage) newDeleter(ctx context.Context) (*s3Deleter, error) {
+	s3Client, err := s.client()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Deleter{
+		storage:   s,
+		s3Client:  s3Client,
+		ctx:       ctx,
+		semaphore: make(chan struct{}, s3DeleteConcurrency),
+	}, nil
+}
+
+// delete schedules the deletion of the keys, blocking while
+// too many batches are in flight
+func (d *s3Deleter) delete(keys []string) {
+	for start := 0; start < len(keys); start += s3MaxDeleteObjects {
+		batch := keys[start:min(start+s3MaxDeleteObjects, len(keys))]
+
+		d.semaphore <- struct{}{}
+		d.wg.Add(1)
+
+		go func(batch []string) {
+			defer d.wg.Done()
+			defer func() { <-d.semaphore }()
+
+			if err := d.deleteBatch(batch); err != nil {
+				d.mu.Lock()
+				d.errs = append(d.errs, err)
+				d.mu.Unlock()
+			}
+		}(batch)
+	}
+}
+
+// wait waits for all the batches, and returns their failures
+func (d *s3Deleter) wait() error {
+	d.wg.Wait()
+
+	return errors.Join(d.errs...)
+}
+
+func (d *s3Deleter) deleteBatch(keys []string) error {
+	objects := make([]types.ObjectIdentifier, len(keys))
+
+	for i, key := range keys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+	}
+
+	// in quiet mode the response lists only the keys, which failed
+	resp, err := d.s3Client.DeleteObjects(d.ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(d.storage.disk.Bucket),
+		Delete: &types.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+
+	if err != nil {
+		return s3Error("delete", keys[0], err)
+	}
+
+	errs := []error{}
+
+	for _, keyError := range resp.Errors {
+		errs = append(errs, newPathError("delete", aws.ToString(keyError.Key), errors.New(aws.ToString(keyError.Code)+": "+aws.ToString(keyError.Message))))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,566 @@
+package filesystem
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// fakeS3 is a minimal in-process S3 compatible server for the tests.
+// It supports path-style addressing of a single bucket, and only the
+// operations used by S3Storage.
+type fakeS3 struct {
+	bucket   string
+	mu       sync.Mutex
+	objects  map[string]fakeS3Object
+	uploads  map[string]*fakeS3Upload
+	uploadID int
+	requests map[string]int // number of requests per operation
+
+	// keys which fail to delete in DeleteObjects, with the error code
+	deleteErrors map[string]string
+}
+
+type fakeS3Object struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+type fakeS3Upload struct {
+	key         string
+	contentType string
+	parts       map[int32][]byte
+}
+
+// newFakeS3Storage starts a fake S3 server, and returns a storage using it
+func newFakeS3Storage(t *testing.T, disk Disk) (*S3Storage, *fakeS3) {
+	fake := newFakeS3(t, &disk)
+	return &S3Storage{disk: disk}, fake
+}
+
+// NewFakeS3Disk starts a fake S3 server, and returns a disk using it,
+// i.e. for the conformance tests in the filesystem_test package
+func NewFakeS3Disk(t *testing.T) Disk {
+	disk := Disk{DiskName: "s3"}
+	newFakeS3(t, &disk)
+	return disk
+}
+
+// newFakeS3 starts a fake S3 server, and points the disk to it
+func newFakeS3(t *testing.T, disk *Disk) *fakeS3 {
+	fake := &fakeS3{
+		bucket:   "bucket",
+		objects:  map[string]fakeS3Object{},
+		uploads:  map[string]*fakeS3Upload{},
+		requests: map[string]int{},
+	}
+
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	disk.Driver = DRIVER_S3
+	disk.Url = server.URL
+	disk.Bucket = fake.bucket
+	disk.UsePathStyleEndpoint = true
+	disk.AWSConfig = &aws.Config{
+		BaseEndpoint: aws.String(server.URL),
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		HTTPClient:   server.Client(),
+	}
+
+	return fake
+}
+
+// requestCount returns the number of requests made for the operation
+func (f *fakeS3) requestCount(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests[operation]
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	if bucket != f.bucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.listObjects(w, query)
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		f.deleteObjects(w, r)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.createUpload(w, r, key)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.uploadPart(w, r, query)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.completeUpload(w, r, key, query)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.abortUpload(w, query)
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		f.listParts(w, query)
+	case r.Method == http.MethodPut && r.Header.Get("x-amz-copy-source") != "":
+		f.copyObject(w, r, key)
+	case r.Method == http.MethodPut:
+		f.putObject(w, r, key)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		f.getObject(w, r, key)
+	case r.Method == http.MethodDelete:
+		f.requests["DeleteObject"]++
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) putObject(w http.ResponseWriter, r *http.Request, key string) {
+	f.requests["PutObject"]++
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		f.error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	f.objects[key] = fakeS3Object{data: data, contentType: r.Header.Get("Content-Type"), modified: time.Now()}
+	w.Header().Set("ETag", fakeS3ETag(data))
+}
+
+func (f *fakeS3) copyObject(w http.ResponseWriter, r *http.Request, key string) {
+	f.requests["CopyObject"]++
+
+	source, _ := url.PathUnescape(r.Header.Get("x-amz-copy-source"))
+	_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+	object, exists := f.objects[sourceKey]
+
+	if !exists {
+		f.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	object.modified = time.Now()
+	f.objects[key] = object
+
+	f.xml(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}{ETag: fakeS3ETag(object.data), LastModified: object.modified.UTC().Format(time.RFC3339)})
+}
+
+func (f *fakeS3) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method == http.MethodHead {
+		f.requests["HeadObject"]++
+	} else {
+		f.requests["GetObject"]++
+	}
+
+	object, exists := f.objects[key]
+
+	if !exists {
+		f.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	data := object.data
+	status := http.StatusOK
+
+	if byteRange := r.Header.Get("Range"); byteRange != "" {
+		start, end, ok := fakeS3ParseRange(byteRange, int64(len(data)))
+
+		if !ok {
+			f.error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(data)))
+		data = data[start:end]
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Type", object.contentType)
+	w.Header().Set("ETag", fakeS3ETag(object.data))
+	w.Header().Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (f *fakeS3) listObjects(w http.ResponseWriter, query url.Values) {
+	f.requests["ListObjectsV2"]++
+
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	maxKeys := 1000
+
+	if query.Get("max-keys") != "" {
+		maxKeys, _ = strconv.Atoi(query.Get("max-keys"))
+	}
+
+	keys := []string{}
+
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+
+	result := struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Name                  string         `xml:"Name"`
+		Prefix                string         `xml:"Prefix"`
+		Delimiter             string         `xml:"Delimiter,omitempty"`
+		MaxKeys               int            `xml:"MaxKeys"`
+		KeyCount              int            `xml:"KeyCount"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+		Contents              []content      `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+	}{
+		Name:              f.bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		MaxKeys:           maxKeys,
+		ContinuationToken: query.Get("continuation-token"),
+	}
+
+	after := query.Get("continuation-token")
+	if after == "" {
+		after = query.Get("start-after")
+	}
+
+	seenPrefixes := map[string]bool{}
+	last := ""
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+
+		entry := key
+		isPrefix := false
+
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				entry = key[:len(prefix)+index+len(delimiter)]
+				isPrefix = true
+			}
+		}
+
+		if seenPrefixes[entry] || (isPrefix && entry <= after) {
+			continue
+		}
+
+		if result.KeyCount >= maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+
+		if isPrefix {
+			seenPrefixes[entry] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: entry})
+			last = entry + "\xff"
+		} else {
+			object := f.objects[key]
+			result.Contents = append(result.Contents, content{
+				Key:          key,
+				LastModified: object.modified.UTC().Format(time.RFC3339),
+				ETag:         fakeS3ETag(object.data),
+				Size:         int64(len(object.data)),
+				StorageClass: "STANDARD",
+			})
+			last = key
+		}
+
+		result.KeyCount++
+	}
+
+	f.xml(w, result)
+}
+
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	f.requests["DeleteObjects"]++
+
+	request := struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}{}
+
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	if len(request.Objects) > 1000 {
+		f.error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+
+	type deleteError struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+
+	result := struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}{}
+
+	for _, object := range request.Objects {
+		if code, fails := f.deleteErrors[object.Key]; fails {
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: code, Message: code})
+			continue
+		}
+
+		delete(f.objects, object.Key)
+
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+		}
+	}
+
+	f.xml(w, result)
+}
+
+func (f *fakeS3) createUpload(w http.ResponseWriter, r *http.Request, key string) {
+	f.requests["CreateMultipartUpload"]++
+
+	f.uploadID++
+	uploadID := "upload-" + strconv.Itoa(f.uploadID)
+	f.uploads[uploadID] = &fakeS3Upload{key: key, contentType: r.Header.Get("Content-Type"), parts: map[int32][]byte{}}
+
+	f.xml(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadId string   `xml:"UploadId"`
+	}{Bucket: f.bucket, Key: key, UploadId: uploadID})
+}
+
+func (f *fakeS3) uploadPart(w http.ResponseWriter, r *http.Request, query url.Values) {
+	f.requests["UploadPart"]++
+
+	upload, exists := f.uploads[query.Get("uploadId")]
+
+	if !exists {
+		f.error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+
+	if err != nil || partNumber < 1 {
+		f.error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		f.error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	upload.parts[int32(partNumber)] = data
+	w.Header().Set("ETag", fakeS3ETag(data))
+}
+
+func (f *fakeS3) completeUpload(w http.ResponseWriter, r *http.Request, key string, query url.Values) {
+	f.requests["CompleteMultipartUpload"]++
+
+	uploadID := query.Get("uploadId")
+	upload, exists := f.uploads[uploadID]
+
+	if !exists {
+		f.error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	request := struct {
+		Parts []struct {
+			PartNumber int32  `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}{}
+
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	data := []byte{}
+	previous := int32(0)
+
+	for _, part := range request.Parts {
+		partData, exists := upload.parts[part.PartNumber]
+
+		if !exists || fakeS3ETag(partData) != part.ETag {
+			f.error(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+
+		if part.PartNumber <= previous {
+			f.error(w, http.StatusBadRequest, "InvalidPartOrder")
+			return
+		}
+
+		previous = part.PartNumber
+		data = append(data, partData...)
+	}
+
+	f.objects[key] = fakeS3Object{data: data, contentType: upload.contentType, modified: time.Now()}
+	delete(f.uploads, uploadID)
+
+	f.xml(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string   `xml:"Bucket"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{Bucket: f.bucket, Key: key, ETag: fakeS3ETag(data)})
+}
+
+func (f *fakeS3) abortUpload(w http.ResponseWriter, query url.Values) {
+	f.requests["AbortMultipartUpload"]++
+
+	if _, exists := f.uploads[query.Get("uploadId")]; !exists {
+		f.error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	delete(f.uploads, query.Get("uploadId"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeS3) listParts(w http.ResponseWriter, query url.Values) {
+	f.requests["ListParts"]++
+
+	upload, exists := f.uploads[query.Get("uploadId")]
+
+	if !exists {
+		f.error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	type part struct {
+		PartNumber int32  `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+		Size       int64  `xml:"Size"`
+	}
+
+	result := struct {
+		XMLName     xml.Name `xml:"ListPartsResult"`
+		Bucket      string   `xml:"Bucket"`
+		Key         string   `xml:"Key"`
+		UploadId    string   `xml:"UploadId"`
+		IsTruncated bool     `xml:"IsTruncated"`
+		Parts       []part   `xml:"Part"`
+	}{Bucket: f.bucket, Key: upload.key, UploadId: query.Get("uploadId")}
+
+	for partNumber, data := range upload.parts {
+		result.Parts = append(result.Parts, part{PartNumber: partNumber, ETag: fakeS3ETag(data), Size: int64(len(data))})
+	}
+
+	sort.Slice(result.Parts, func(i, j int) bool {
+		return result.Parts[i].PartNumber < result.Parts[j].PartNumber
+	})
+
+	f.xml(w, result)
+}
+
+func (f *fakeS3) xml(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(value)
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func fakeS3ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// fakeS3ParseRange parses a "bytes=start-end" range header, and
+// returns the start and end (exclusive) offsets
+func fakeS3ParseRange(byteRange string, size int64) (int64, int64, bool) {
+	startStr, endStr, ok := strings.Cut(strings.TrimPrefix(byteRange, "bytes="), "-")
+
+	if !ok {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+
+	end := size
+
+	if endStr != "" {
+		last, err := strconv.ParseInt(endStr, 10, 64)
+
+		if err != nil || last < start {
+			return 0, 0, false
+		}
+
+		end = min(last+1, size)
+	}
+
+	return start, end, true
+}
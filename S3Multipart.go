@@ -0,0 +1,296 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const s3DefaultMultipartThreshold = 64 * 1024 * 1024
+const s3DefaultMultipartPartSize = 16 * 1024 * 1024
+const s3DefaultMultipartConcurrency = 4
+const s3MinMultipartPartSize = 5 * 1024 * 1024 // except the last part
+const s3MaxParts = 10000
+
+// UploadedPart is a part of a multipart upload, which was uploaded to S3.
+// The parts are needed to complete the upload, so keep them (i.e. in the
+// session of the user) when resuming an upload across HTTP requests.
+type UploadedPart struct {
+	PartNumber int32
+	ETag       string
+	Size       int64
+}
+
+// InitiateUpload starts a multipart upload, and returns its ID.
+// Upload the parts with UploadPart, and finish the upload with
+// CompleteUpload or AbortUpload.
+func (s *S3Storage) InitiateUpload(filePath string) (string, error) {
+	return s.initiateUpload(context.Background(), cleanPath(filePath), "")
+}
+
+// UploadPart uploads a single part of a multipart upload. The part
+// numbers start at 1, and set the order of the parts in the file.
+// Uploading a part with the same number again replaces it.
+func (s *S3Storage) UploadPart(filePath, uploadID string, partNumber int32, reader io.Reader, size int64) (UploadedPart, error) {
+	return s.uploadPart(context.Background(), cleanPath(filePath), uploadID, partNumber, reader, size)
+}
+
+// UploadedParts lists the parts already uploaded, i.e. to find
+// where to resume an interrupted upload
+func (s *S3Storage) UploadedParts(filePath, uploadID string) ([]UploadedPart, error) {
+	s3Client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+
+	key := cleanPath(filePath)
+	parts := []UploadedPart{}
+
+	paginator := s3.NewListPartsPaginator(s3Client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.disk.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+
+		if err != nil {
+			return nil, s3Error("upload", key, err)
+		}
+
+		for _, part := range page.Parts {
+			parts = append(parts, UploadedPart{
+				PartNumber: aws.ToInt32(part.PartNumber),
+				ETag:       aws.ToString(part.ETag),
+				Size:       aws.ToInt64(part.Size),
+			})
+		}
+	}
+
+	return parts, nil
+}
+
+// CompleteUpload assembles the uploaded parts into the file
+func (s *S3Storage) CompleteUpload(filePath, uploadID string, parts []UploadedPart) error {
+	return s.completeUpload(context.Background(), cleanPath(filePath), uploadID, parts)
+}
+
+// AbortUpload cancels a multipart upload, and removes the uploaded parts
+func (s *S3Storage) AbortUpload(filePath, uploadID string) error {
+	return s.abortUpload(context.Background(), cleanPath(filePath), uploadID)
+}
+
+func (s *S3Storage) initiateUpload(ctx context.Context, key, contentType string) (string, error) {
+	s3Client, err := s.client()
+	if err != nil {
+		return "", err
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(s.disk.Bucket),
+		Key:                aws.String(key),
+		ContentDisposition: aws.String("attachment"),
+		ACL:                types.ObjectCannedACLPublicRead,
+	}
+
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	resp, err := s3Client.CreateMultipartUpload(ctx, input)
+
+	if err != nil {
+		return "", s3Error("upload", key, err)
+	}
+
+	return aws.ToString(resp.UploadId), nil
+}
+
+func (s *S3Storage) uploadPart(ctx context.Context, key, uploadID string, partNumber int32, reader io.Reader, size int64) (UploadedPart, error) {
+	s3Client, err := s.client()
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	resp, err := s3Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.disk.Bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          reader,
+		ContentLength: aws.Int64(size),
+	})
+
+	if err != nil {
+		return UploadedPart{}, s3Error("upload", key, err)
+	}
+
+	return UploadedPart{
+		PartNumber: partNumber,
+		ETag:       aws.ToString(resp.ETag),
+		Size:       size,
+	}, nil
+}
+
+func (s *S3Storage) completeUpload(ctx context.Context, key, uploadID string, parts []UploadedPart) error {
+	s3Client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	sorted := append([]UploadedPart{}, parts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PartNumber < sorted[j].PartNumber
+	})
+
+	completed := make([]types.CompletedPart, len(sorted))
+
+	for i, part := range sorted {
+		completed[i] = types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		}
+	}
+
+	_, err = s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.disk.Bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+
+	return s3Error("upload", key, err)
+}
+
+func (s *S3Storage) abortUpload(ctx context.Context, key, uploadID string) error {
+	s3Client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	_, err = s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.disk.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	return s3Error("upload", key, err)
+}
+
+// multipartUpload uploads the contents of the reader in parts, with
+// at most MultipartConcurrency parts in flight. The upload is aborted
+// if any of the parts fails.
+func (s *S3Storage) multipartUpload(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	uploadID, err := s.initiateUpload(ctx, key, contentType)
+
+	if err != nil {
+		return err
+	}
+
+	partSize := s.multipartPartSize()
+
+	if size/partSize >= s3MaxParts {
+		partSize = size/s3MaxParts + 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := []UploadedPart{}
+	errs := []error{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, s.multipartConcurrency())
+
+	for partNumber := int32(1); ; partNumber++ {
+		buffer := make([]byte, partSize)
+		n, readErr := io.ReadFull(reader, buffer)
+
+		if n == 0 && partNumber > 1 {
+			break
+		}
+
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			mu.Lock()
+			errs = append(errs, readErr)
+			mu.Unlock()
+			break
+		}
+
+		semaphore <- struct{}{}
+
+		mu.Lock()
+		failed := len(errs) > 0
+		mu.Unlock()
+
+		if failed {
+			<-semaphore
+			break
+		}
+
+		wg.Add(1)
+
+		go func(partNumber int32, data []byte) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			part, err := s.uploadPart(ctx, key, uploadID, partNumber, bytes.NewReader(data), int64(len(data)))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, err)
+				cancel()
+				return
+			}
+
+			parts = append(parts, part)
+		}(partNumber, buffer[:n])
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		abortErr := s.abortUpload(context.Background(), key, uploadID)
+		return errors.Join(append(errs, abortErr)...)
+	}
+
+	return s.completeUpload(ctx, key, uploadID, parts)
+}
+
+func (s *S3Storage) multipartThreshold() int64 {
+	if s.disk.MultipartThreshold > 0 {
+		return s.disk.MultipartThreshold
+	}
+
+	return s3DefaultMultipartThreshold
+}
+
+func (s *S3Storage) multipartPartSize() int64 {
+	if s.disk.MultipartPartSize > 0 {
+		return s.disk.MultipartPartSize
+	}
+
+	return s3DefaultMultipartPartSize
+}
+
+func (s *S3Storage) multipartConcurrency() int {
+	if s.disk.MultipartConcurrency > 0 {
+		return s.disk.MultipartConcurrency
+	}
+
+	return s3DefaultMultipartConcurrency
+}
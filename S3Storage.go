@@ -1,18 +1,25 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
+	"net/url"
+	"os"
 	"path"
 
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/goravel/framework/contracts/filesystem"
 	"github.com/goravel/framework/support/file"
 	"github.com/gouniverse/utils"
@@ -22,16 +29,102 @@ import (
 // i.e. AWS S3, DigitalOcean Spaces, Minio, etc
 type S3Storage struct {
 	disk Disk
+
+	// the client is built once, on first use, and shared by all methods
+	clientOnce sync.Once
+	s3Client   *s3.Client
+}
+
+var _ StorageInterface = (*S3Storage)(nil)        // verify it extends the storage interface
+var _ StorageContextInterface = (*S3Storage)(nil) // verify it extends the storage context interface
+var _ StorageStreamInterface = (*S3Storage)(nil)  // verify it extends the storage stream interface
+var _ StorageRangeInterface = (*S3Storage)(nil)   // verify it extends the storage range interface
+var _ StoragePageInterface = (*S3Storage)(nil)    // verify it extends the storage page interface
+var _ StorageWalkInterface = (*S3Storage)(nil)    // verify it extends the storage walk interface
+var _ StorageStatInterface = (*S3Storage)(nil)    // verify it extends the storage stat interface
+var _ StorageCopyInterface = (*S3Storage)(nil)    // verify it extends the storage copy interface
+
+// s3Driver creates the storage of a DRIVER_S3 disk
+func s3Driver(disk Disk) (StorageInterface, error) {
+	return &S3Storage{disk: disk}, nil
 }
 
-var _ StorageInterface = (*S3Storage)(nil) // verify it extends the storage interface
+func validateS3Disk(disk Disk) error {
+	// a supplied client or config brings its own region and credentials
+	configured := disk.S3Client != nil || disk.AWSConfig != nil
+
+	if disk.Region == "" && !configured {
+		return errors.New("region is required field")
+	}
+
+	if disk.Key == "" && !configured {
+		return errors.New("key is required field")
+	}
+
+	if disk.Secret == "" && !configured {
+		return errors.New("secret is required field")
+	}
+
+	// otherwise S3 only rejects the parts when completing the upload
+	if disk.MultipartPartSize > 0 && disk.MultipartPartSize < s3MinMultipartPartSize {
+		return errors.New("multipart part size must be at least 5 MB")
+	}
+
+	return nil
+}
 
+// client returns the S3 client of the storage. A client supplied
+// in Disk.S3Client is used as is. Otherwise the client is built from
+// Disk.AWSConfig if set, keeping its endpoint resolution, unless
+// Disk.Endpoint is set, or from the static Key and Secret, with all
+// the requests sent to the endpoint in Disk.Url.
 func (s *S3Storage) client() (*s3.Client, error) {
+	s.clientOnce.Do(func() {
+		if s.disk.S3Client != nil {
+			s.s3Client = s.disk.S3Client
+			return
+		}
+
+		optFns := []func(*s3.Options){func(options *s3.Options) {
+			options.UsePathStyle = s.disk.UsePathStyleEndpoint
+
+			if s.disk.Region != "" {
+				options.Region = s.disk.Region
+			}
+		}}
+
+		if s.disk.AWSConfig != nil {
+			if s.disk.Endpoint != "" {
+				optFns = append(optFns, func(options *s3.Options) {
+					options.BaseEndpoint = aws.String(s.disk.Endpoint)
+				})
+			}
+
+			s.s3Client = s3.NewFromConfig(*s.disk.AWSConfig, optFns...)
+			return
+		}
+
+		if s.disk.Url != "" {
+			optFns = append(optFns, func(options *s3.Options) {
+				options.EndpointResolver = s.endpointResolver()
+			})
+		}
+
+		s.s3Client = s3.New(s3.Options{
+			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(s.disk.Key, s.disk.Secret, "")),
+		}, optFns...)
+	})
+
+	return s.s3Client, nil
+}
+
+// endpointResolver resolves all requests to the endpoint in Disk.Url
+func (s *S3Storage) endpointResolver() s3.EndpointResolver {
 	endpoint := strings.ReplaceAll(s.disk.Url, "https://", "")
 	endpoint, _ = strings.CutPrefix(endpoint, s.disk.Bucket+".") // remove bucket prefix (i.e. DigitalOcean Spaces)
 	endpoint, _ = strings.CutSuffix(endpoint, "/"+s.disk.Bucket) // remove bucket suffix (i.e. Minio)
 
-	customResolver := s3.EndpointResolverFunc(func(region string, options s3.EndpointResolverOptions) (aws.Endpoint, error) {
+	return s3.EndpointResolverFunc(func(region string, options s3.EndpointResolverOptions) (aws.Endpoint, error) {
 		return aws.Endpoint{
 			// PartitionID:   "aws",
 			URL: "https://" + endpoint,
@@ -39,172 +132,413 @@ func (s *S3Storage) client() (*s3.Client, error) {
 			HostnameImmutable: true,
 		}, nil
 	})
-
-	client := s3.New(s3.Options{
-		Region:           s.disk.Region,
-		Credentials:      aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(s.disk.Key, s.disk.Secret, "")),
-		EndpointResolver: customResolver,
-		UsePathStyle:     s.disk.UsePathStyleEndpoint,
-	})
-	return client, nil
 }
 
 func (s *S3Storage) Copy(originFile, targetFile string) error {
+	return s.CopyContext(context.Background(), originFile, targetFile)
+}
+
+func (s *S3Storage) CopyContext(ctx context.Context, originFile, targetFile string) error {
 	s3Client, err := s.client()
 	if err != nil {
-		panic(err)
+		return err
 	}
-	ctx := context.TODO()
 	_, err = s3Client.CopyObject(ctx, &s3.CopyObjectInput{
 		Bucket:     aws.String(s.disk.Bucket),
-		CopySource: aws.String(s.disk.Bucket + "/" + originFile),
-		Key:        aws.String(targetFile),
+		CopySource: aws.String(s3CopySource(s.disk.Bucket, cleanPath(originFile))),
+		Key:        aws.String(cleanPath(targetFile)),
 	})
 
-	return err
+	return s3Error("copy", originFile, err)
+}
+
+// s3CopySource returns the source of a CopyObject request, which
+// S3 expects URL encoded, keeping the slashes between the segments
+func s3CopySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return bucket + "/" + strings.Join(segments, "/")
 }
 
 func (s *S3Storage) DeleteFile(filePaths []string) error {
-	s3Client, err := s.client()
+	return s.DeleteFileContext(context.Background(), filePaths)
+}
+
+func (s *S3Storage) DeleteFileContext(ctx context.Context, filePaths []string) error {
+	deleter, err := s.newDeleter(ctx)
+
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	var objectIdentifiers []types.ObjectIdentifier
-	for _, file := range filePaths {
-		objectIdentifiers = append(objectIdentifiers, types.ObjectIdentifier{
-			Key: aws.String(file),
-		})
-	}
+	keys := make([]string, len(filePaths))
 
-	quiet := true
-	input := &s3.DeleteObjectsInput{
-		Bucket: aws.String(s.disk.Bucket),
-		Delete: &types.Delete{
-			Objects: objectIdentifiers,
-			Quiet:   &quiet,
-		},
+	for i, file := range filePaths {
+		keys[i] = cleanPath(file)
 	}
-	ctx := context.TODO()
-	_, err = s3Client.DeleteObjects(ctx, input)
 
-	return err
+	deleter.delete(keys)
+
+	return deleter.wait()
 }
 
-// DeleteDirectory deletes a directory
+// DeleteDirectory deletes a directory, together with all its contents,
+// using batched DeleteObjects requests
 func (s *S3Storage) DeleteDirectory(directory string) error {
+	return s.DeleteDirectoryContext(context.Background(), directory)
+}
+
+func (s *S3Storage) DeleteDirectoryContext(ctx context.Context, directory string) error {
 	s3Client, err := s.client()
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
+	directory = s.toValidS3DirPath(directory)
+
+	if directory == "" {
+		return newPathError("delete", directory, errors.New("can not delete the root directory"))
 	}
 
-	listObjectsV2Response, err := s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.disk.Bucket),
-		Prefix: aws.String(directory),
-	})
+	deleter, err := s.newDeleter(ctx)
 
 	if err != nil {
 		return err
 	}
 
-	if len(listObjectsV2Response.Contents) == 0 {
-		return nil
-	}
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.disk.Bucket),
+		Prefix: aws.String(directory),
+	})
 
-	for {
-		for _, item := range listObjectsV2Response.Contents {
-			_, err = s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-				Bucket: aws.String(s.disk.Bucket),
-				Key:    item.Key,
-			})
-			if err != nil {
-				return err
-			}
+	// each listed page is deleted, while the next one is listed
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return errors.Join(s3Error("delete", directory, err), deleter.wait())
 		}
 
-		if *listObjectsV2Response.IsTruncated {
-			listObjectsV2Response, err = s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
-				Bucket:            aws.String(s.disk.Bucket),
-				ContinuationToken: listObjectsV2Response.ContinuationToken,
-			})
-			if err != nil {
-				return err
-			}
-		} else {
-			break
+		keys := make([]string, len(page.Contents))
+
+		for i, item := range page.Contents {
+			keys[i] = aws.ToString(item.Key)
 		}
+
+		deleter.delete(keys)
 	}
 
-	return nil
+	return deleter.wait()
 }
 
 // Directories lists the sub-directories in the specified directory
 func (s *S3Storage) Directories(dir string) ([]string, error) {
+	return s.DirectoriesContext(context.Background(), dir)
+}
+
+func (s *S3Storage) DirectoriesContext(ctx context.Context, dir string) ([]string, error) {
+	dirs := []string{}
+
+	err := s.list(ctx, dir, func(page *s3.ListObjectsV2Output) {
+		for _, commonPrefix := range page.CommonPrefixes {
+			dirs = append(dirs, strings.TrimSuffix(*commonPrefix.Prefix, "/"))
+		}
+	})
+
+	if err != nil {
+		return []string{}, err
+	}
+
+	return dirs, nil
+}
+
+// Files lists the files in the specified directory
+func (s *S3Storage) Files(dir string) ([]string, error) {
+	return s.FilesContext(context.Background(), dir)
+}
+
+func (s *S3Storage) FilesContext(ctx context.Context, dir string) ([]string, error) {
+	files := []string{}
+
+	err := s.list(ctx, dir, func(page *s3.ListObjectsV2Output) {
+		files = append(files, s.pageFiles(dir, page)...)
+	})
+
+	if err != nil {
+		return []string{}, err
+	}
+
+	return files, nil
+}
+
+// ListPage lists a page of the entries of the directory. The cursor
+// is the S3 continuation token.
+func (s *S3Storage) ListPage(dir string, cursor string, limit int) (Page, error) {
+	return s.ListPageContext(context.Background(), dir, cursor, limit)
+}
+
+func (s *S3Storage) ListPageContext(ctx context.Context, dir string, cursor string, limit int) (Page, error) {
 	s3Client, err := s.client()
 
 	if err != nil {
-		panic(err)
+		return Page{}, err
+	}
+
+	if limit <= 0 {
+		limit = DEFAULT_PAGE_LIMIT
 	}
 
 	input := &s3.ListObjectsV2Input{
 		Bucket:    aws.String(s.disk.Bucket),
 		Prefix:    aws.String(s.toValidS3DirPath(dir)),
 		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int32(int32(min(limit, DEFAULT_PAGE_LIMIT))),
+	}
+
+	if cursor != "" {
+		input.ContinuationToken = aws.String(cursor)
 	}
 
-	ctx := context.TODO()
 	objects, err := s3Client.ListObjectsV2(ctx, input)
 
 	if err != nil {
-		return []string{}, err
+		return Page{}, s3Error("list", dir, err)
 	}
 
-	dirs := []string{}
+	page := Page{Directories: []string{}, Files: s.pageFiles(dir, objects)}
+
 	for _, commonPrefix := range objects.CommonPrefixes {
-		dirs = append(dirs, *commonPrefix.Prefix)
+		page.Directories = append(page.Directories, strings.TrimSuffix(*commonPrefix.Prefix, "/"))
 	}
 
-	return dirs, nil
+	if aws.ToBool(objects.IsTruncated) {
+		page.NextCursor = aws.ToString(objects.NextContinuationToken)
+	}
+
+	return page, nil
 }
 
-// Files lists the files in the specified directory
-func (s *S3Storage) Files(dir string) ([]string, error) {
+// list calls fn for each page of the listing of the directory
+func (s *S3Storage) list(ctx context.Context, dir string, fn func(page *s3.ListObjectsV2Output)) error {
 	s3Client, err := s.client()
 
 	if err != nil {
-		return []string{}, err
+		return err
 	}
 
-	input := &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
 		Bucket:    aws.String(s.disk.Bucket),
 		Prefix:    aws.String(s.toValidS3DirPath(dir)),
 		Delimiter: aws.String("/"),
-	}
+	})
 
-	ctx := context.TODO()
-	objects, err := s3Client.ListObjectsV2(ctx, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 
-	if err != nil {
-		return []string{}, err
+		if err != nil {
+			return s3Error("list", dir, err)
+		}
+
+		fn(page)
 	}
 
+	return nil
+}
+
+// pageFiles returns the files of a listing page, without the
+// marker object of the directory itself
+func (s *S3Storage) pageFiles(dir string, page *s3.ListObjectsV2Output) []string {
 	files := []string{}
-	for _, object := range objects.Contents {
+
+	for _, object := range page.Contents {
 		if s.toValidS3DirPath(dir) == *object.Key {
 			continue
 		}
 		files = append(files, *object.Key)
 	}
 
-	return files, nil
+	return files
+}
+
+// Stat returns the metadata of a file or a directory. A path without
+// an object is a directory, when there are objects under it.
+func (s *S3Storage) Stat(file string) (FileInfo, error) {
+	return s.StatContext(context.Background(), file)
+}
+
+func (s *S3Storage) StatContext(ctx context.Context, file string) (FileInfo, error) {
+	s3Client, err := s.client()
+
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	key := cleanPath(file)
+
+	if key == "" {
+		return newFileInfo(diskVisibility(s.disk), "", true, 0, time.Time{}), nil
+	}
+
+	resp, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.disk.Bucket),
+		Key:    aws.String(key),
+	})
+
+	if err == nil {
+		info := newFileInfo(diskVisibility(s.disk), key, false, aws.ToInt64(resp.ContentLength), aws.ToTime(resp.LastModified))
+		info.ETag = aws.ToString(resp.ETag)
+
+		if contentType := aws.ToString(resp.ContentType); contentType != "" {
+			info.MimeType = contentType
+		}
+
+		return info, nil
+	}
+
+	if err = s3Error("stat", file, err); !errors.Is(err, ErrNotFound) {
+		return FileInfo{}, err
+	}
+
+	objects, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.disk.Bucket),
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int32(1),
+	})
+
+	if err != nil {
+		return FileInfo{}, s3Error("stat", file, err)
+	}
+
+	if len(objects.Contents) == 0 {
+		return FileInfo{}, newPathError("stat", file, ErrNotFound)
+	}
+
+	// as in the listings, directories have no modification time
+	return newFileInfo(diskVisibility(s.disk), key, true, 0, time.Time{}), nil
+}
+
+// List lists the files and sub-directories of the directory with their
+// metadata, as returned by the listing
+func (s *S3Storage) List(dir string) ([]FileInfo, error) {
+	return s.ListContext(context.Background(), dir)
+}
+
+func (s *S3Storage) ListContext(ctx context.Context, dir string) ([]FileInfo, error) {
+	infos := []FileInfo{}
+
+	err := s.list(ctx, dir, func(page *s3.ListObjectsV2Output) {
+		for _, commonPrefix := range page.CommonPrefixes {
+			infos = append(infos, newFileInfo(diskVisibility(s.disk), strings.TrimSuffix(*commonPrefix.Prefix, "/"), true, 0, time.Time{}))
+		}
+
+		for _, object := range page.Contents {
+			if s.toValidS3DirPath(dir) == *object.Key {
+				continue
+			}
+
+			info := newFileInfo(diskVisibility(s.disk), *object.Key, false, aws.ToInt64(object.Size), aws.ToTime(object.LastModified))
+			info.ETag = aws.ToString(object.ETag)
+			infos = append(infos, info)
+		}
+	})
+
+	if err != nil {
+		return []FileInfo{}, err
+	}
+
+	sortFileInfos(infos)
+
+	return infos, nil
+}
+
+// Walk calls fn for every file and directory under root. The objects are
+// listed by prefix without a delimiter, and the directories are derived
+// from the directory markers and the object keys.
+func (s *S3Storage) Walk(root string, fn WalkFunc) error {
+	return s.WalkContext(context.Background(), root, fn)
+}
+
+func (s *S3Storage) WalkContext(ctx context.Context, root string, fn WalkFunc) error {
+	s3Client, err := s.client()
+
+	if err != nil {
+		return walkRootError(root, err, fn)
+	}
+
+	prefix := s.toValidS3DirPath(cleanPath(root))
+
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.disk.Bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	entries := []pageEntry{}
+	directories := map[string]bool{}
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return walkRootError(root, s3Error("walk", root, err), fn)
+		}
+
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+
+			// every parent inside the root is a directory, even without a marker
+			relativeKey := key[len(prefix):]
+
+			for index, char := range relativeKey {
+				if char == '/' {
+					directories[prefix+relativeKey[:index]] = true
+				}
+			}
+
+			if !strings.HasSuffix(key, "/") {
+				entries = append(entries, pageEntry{path: key})
+			}
+		}
+	}
+
+	for directory := range directories {
+		entries = append(entries, pageEntry{path: directory, isDir: true})
+	}
+
+	return walkEntries(entries, fn)
+}
+
+// AllFiles lists all the files in the directory and its sub-directories
+func (s *S3Storage) AllFiles(dir string) ([]string, error) {
+	return s.AllFilesContext(context.Background(), dir)
+}
+
+func (s *S3Storage) AllFilesContext(ctx context.Context, dir string) ([]string, error) {
+	return walkAll(func(root string, fn WalkFunc) error {
+		return s.WalkContext(ctx, root, fn)
+	}, dir, false)
+}
+
+// AllDirectories lists all the sub-directories of the directory, recursively
+func (s *S3Storage) AllDirectories(dir string) ([]string, error) {
+	return s.AllDirectoriesContext(context.Background(), dir)
+}
+
+func (s *S3Storage) AllDirectoriesContext(ctx context.Context, dir string) ([]string, error) {
+	return walkAll(func(root string, fn WalkFunc) error {
+		return s.WalkContext(ctx, root, fn)
+	}, dir, true)
 }
 
 func (s *S3Storage) Exists(file string) (bool, error) {
+	return s.ExistsContext(context.Background(), file)
+}
+
+func (s *S3Storage) ExistsContext(ctx context.Context, file string) (bool, error) {
 	s3Client, err := s.client()
 
 	if err != nil {
@@ -213,14 +547,44 @@ func (s *S3Storage) Exists(file string) (bool, error) {
 
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(s.disk.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(cleanPath(file)),
 	}
 
-	ctx := context.TODO()
-
 	_, err = s3Client.HeadObject(ctx, input)
 
-	return err == nil, err
+	if err == nil {
+		return true, nil
+	}
+
+	err = s3Error("exists", file, err)
+
+	if errors.Is(err, ErrNotFound) {
+		return s.isDirectory(ctx, file)
+	}
+
+	return false, err
+}
+
+// isDirectory checks if there are any objects under the directory, as
+// S3 has no directories, only a marker or the keys of the files in them
+func (s *S3Storage) isDirectory(ctx context.Context, dir string) (bool, error) {
+	s3Client, err := s.client()
+
+	if err != nil {
+		return false, err
+	}
+
+	output, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.disk.Bucket),
+		Prefix:  aws.String(s.toValidS3DirPath(cleanPath(dir))),
+		MaxKeys: aws.Int32(1),
+	})
+
+	if err != nil {
+		return false, s3Error("exists", dir, err)
+	}
+
+	return len(output.Contents) > 0, nil
 }
 
 // func (r *S3) Get(file string) (string, error) {
@@ -243,12 +607,13 @@ func (s *S3Storage) Exists(file string) (bool, error) {
 // 	return string(data), nil
 // }
 
+// MakeDirectory creates an empty directory marker object, i.e. "dir/"
 func (s *S3Storage) MakeDirectory(directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
-	}
+	return s.MakeDirectoryContext(context.Background(), directory)
+}
 
-	return s.Put(directory, []byte(""))
+func (s *S3Storage) MakeDirectoryContext(ctx context.Context, directory string) error {
+	return s.putObject(ctx, s.toValidS3DirPath(directory), []byte(""))
 }
 
 func (s *S3Storage) Missing(file string) (bool, error) {
@@ -257,42 +622,171 @@ func (s *S3Storage) Missing(file string) (bool, error) {
 }
 
 func (s *S3Storage) Move(oldFile, newFile string) error {
-	if err := s.Copy(oldFile, newFile); err != nil {
+	return s.MoveContext(context.Background(), oldFile, newFile)
+}
+
+// MoveContext copies the file, or the objects under the directory, and
+// then deletes the originals, as S3 has no rename. An existing target
+// is not replaced, as on the other storages.
+func (s *S3Storage) MoveContext(ctx context.Context, oldFile, newFile string) error {
+	if cleanPath(oldFile) == cleanPath(newFile) {
+		return newPathError("move", oldFile, errors.New("origin and target paths are the same"))
+	}
+
+	targetExists, err := s.ExistsContext(ctx, newFile)
+
+	if err != nil {
+		return err
+	}
+
+	if targetExists {
+		return newPathError("move", newFile, ErrAlreadyExists)
+	}
+
+	isDirectory, err := s.isDirectory(ctx, oldFile)
+
+	if err != nil {
+		return err
+	}
+
+	if !isDirectory {
+		if err := s.CopyContext(ctx, oldFile, newFile); err != nil {
+			return err
+		}
+
+		return s.DeleteFileContext(ctx, []string{oldFile})
+	}
+
+	if strings.HasPrefix(cleanPath(newFile), cleanPath(oldFile)+PATH_SEPARATOR) {
+		return newPathError("move", newFile, errors.New("can not move a directory inside itself"))
+	}
+
+	if err := s.CopyDirectoryContext(ctx, oldFile, newFile); err != nil {
 		return err
 	}
 
-	return s.DeleteFile([]string{oldFile})
+	return s.DeleteDirectoryContext(ctx, oldFile)
 }
 
 func (s *S3Storage) Put(filePath string, content []byte) error {
-	// mimeType := mimetype.Detect(content)
+	return s.PutContext(context.Background(), filePath, content)
+}
 
-	s3Client, err := s.client()
+func (s *S3Storage) PutContext(ctx context.Context, filePath string, content []byte) error {
+	return s.putObject(ctx, cleanPath(filePath), content)
+}
+
+// putObject uploads the content under the exact object key
+func (s *S3Storage) putObject(ctx context.Context, key string, content []byte) error {
+	return s.putStream(ctx, key, bytes.NewReader(content), int64(len(content)))
+}
+
+// PutStream uploads the contents of the reader. S3 requires the content
+// length up front, so when the size is not known (-1) the contents are
+// spooled to a temporary file first. Contents larger than the multipart
+// threshold are uploaded in parts.
+func (s *S3Storage) PutStream(filePath string, reader io.Reader, size int64) error {
+	return s.PutStreamContext(context.Background(), filePath, reader, size)
+}
+
+func (s *S3Storage) PutStreamContext(ctx context.Context, filePath string, reader io.Reader, size int64) error {
+	return s.putStream(ctx, cleanPath(filePath), reader, size)
+}
+
+// putStream uploads the contents of the reader under the exact object key
+func (s *S3Storage) putStream(ctx context.Context, key string, reader io.Reader, size int64) error {
+	if size < 0 {
+		file, err := os.CreateTemp("", "filesystem-*")
+
+		if err != nil {
+			return err
+		}
+
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		size, err = io.Copy(file, reader)
+
+		if err != nil {
+			return s3Error("put", key, err)
+		}
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		reader = file
+	}
+
+	contentType, reader, err := detectContentType(reader)
 	if err != nil {
-		panic(err)
+		return s3Error("put", key, err)
 	}
 
-	// cfmt.Successln("File upload: ", filePath)
-	// cfmt.Successln("Mimetype: ", mimeType)
+	if size > s.multipartThreshold() {
+		return s.multipartUpload(ctx, key, reader, size, contentType)
+	}
+
+	s3Client, err := s.client()
+	if err != nil {
+		return err
+	}
 
-	size := int64(len(content))
 	input := &s3.PutObjectInput{
-		Bucket: aws.String(s.disk.Bucket),
-		Key:    aws.String(filePath),
-		Body:   strings.NewReader(string(content)),
-		// ContentLength:      int64(len(content)),
-		// ContentType:        aws.String(mtype.String()),
-		// Body:               bytes.NewReader(buffer),
+		Bucket:             aws.String(s.disk.Bucket),
+		Key:                aws.String(key),
+		Body:               reader,
 		ContentLength:      &size,
-		ContentType:        aws.String(http.DetectContentType(content)),
+		ContentType:        aws.String(contentType),
 		ContentDisposition: aws.String("attachment"),
 		ACL:                types.ObjectCannedACLPublicRead,
-		// ACL:                aws.String("public-read"),
 	}
 
-	_, err = s3Client.PutObject(context.TODO(), input)
+	_, err = s3Client.PutObject(ctx, input)
+
+	return s3Error("put", key, err)
+}
 
-	return err
+// OpenReader opens the object for reading, the body is streamed from S3
+func (s *S3Storage) OpenReader(file string) (io.ReadCloser, error) {
+	return s.OpenReaderContext(context.Background(), file)
+}
+
+func (s *S3Storage) OpenReaderContext(ctx context.Context, file string) (io.ReadCloser, error) {
+	s3Client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.disk.Bucket),
+		Key:    aws.String(cleanPath(file)),
+	})
+	if err != nil {
+		return nil, s3Error("read", file, err)
+	}
+
+	return resp.Body, nil
+}
+
+// OpenWriter opens the object for writing. The written data is spooled
+// to a temporary file, and uploaded when the writer is closed.
+func (s *S3Storage) OpenWriter(file string) (io.WriteCloser, error) {
+	return s.OpenWriterContext(context.Background(), file)
+}
+
+func (s *S3Storage) OpenWriterContext(ctx context.Context, file string) (io.WriteCloser, error) {
+	key := cleanPath(file)
+
+	writer, err := newTempFileWriter(func(tempFile *os.File, size int64) error {
+		return s.putStream(ctx, key, tempFile, size)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
 }
 
 func (s *S3Storage) PutFile(filePath string, source filesystem.File) (string, error) {
@@ -318,50 +812,145 @@ func (s *S3Storage) PutFileAs(filePath string, source filesystem.File, name stri
 }
 
 func (s *S3Storage) ReadFile(file string) ([]byte, error) {
+	return s.ReadFileContext(context.Background(), file)
+}
+
+func (s *S3Storage) ReadFileContext(ctx context.Context, file string) ([]byte, error) {
 	s3Client, err := s.client()
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := context.TODO()
-
 	resp, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.disk.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(cleanPath(file)),
 	})
 	if err != nil {
-		return nil, err
+		return nil, s3Error("read", file, err)
 	}
 	defer resp.Body.Close()
 
 	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, s3Error("read", file, err)
+	}
+
+	return data, nil
+}
+
+// ReadRange reads length bytes of the object, starting at offset,
+// using the HTTP Range header
+func (s *S3Storage) ReadRange(file string, offset, length int64) ([]byte, error) {
+	return s.ReadRangeContext(context.Background(), file, offset, length)
+}
+
+func (s *S3Storage) ReadRangeContext(ctx context.Context, file string, offset, length int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, newPathError("read", file, errNegativeOffset)
+	}
+
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	body, err := s.getRange(ctx, cleanPath(file), offset, length)
+
 	if err != nil {
 		return nil, err
 	}
 
+	if body == nil {
+		return []byte{}, nil
+	}
+
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+
+	if err != nil {
+		return nil, s3Error("read", file, err)
+	}
+
 	return data, nil
 }
 
+// Open opens the object for random access reading. Reads stream the
+// object from the current offset, and seeking starts a new ranged
+// request on the next read.
+func (s *S3Storage) Open(file string) (io.ReadSeekCloser, error) {
+	return s.OpenContext(context.Background(), file)
+}
+
+func (s *S3Storage) OpenContext(ctx context.Context, file string) (io.ReadSeekCloser, error) {
+	size, err := s.SizeContext(ctx, file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Reader{ctx: ctx, storage: s, key: cleanPath(file), size: size}, nil
+}
+
+// getRange fetches a range of the object. A negative length reads to
+// the end of the object. A nil body is returned, if the range starts
+// past the end of the object.
+func (s *S3Storage) getRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s3Client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+
+	if length > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	resp, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.disk.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange),
+	})
+
+	var apiErr smithy.APIError
+
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, s3Error("read", key, err)
+	}
+
+	return resp.Body, nil
+}
+
 func (s *S3Storage) Size(file string) (int64, error) {
+	return s.SizeContext(context.Background(), file)
+}
+
+func (s *S3Storage) SizeContext(ctx context.Context, file string) (int64, error) {
 	s3Client, err := s.client()
 	if err != nil {
 		return -1, err
 	}
 
-	ctx := context.TODO()
-
 	resp, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.disk.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(cleanPath(file)),
 	})
 	if err != nil {
-		return -1, err
+		return -1, s3Error("size", file, err)
 	}
 
 	return *resp.ContentLength, nil
 }
 
 func (s *S3Storage) LastModified(file string) (time.Time, error) {
+	return s.LastModifiedContext(context.Background(), file)
+}
+
+func (s *S3Storage) LastModifiedContext(ctx context.Context, file string) (time.Time, error) {
 	s3Client, err := s.client()
 
 	if err != nil {
@@ -370,13 +959,12 @@ func (s *S3Storage) LastModified(file string) (time.Time, error) {
 
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(s.disk.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(cleanPath(file)),
 	}
-	ctx := context.TODO()
 	resp, err := s3Client.HeadObject(ctx, input)
 
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, s3Error("lastmodified", file, err)
 	}
 
 	l, err := time.LoadLocation("Europe/London")
@@ -389,7 +977,106 @@ func (s *S3Storage) LastModified(file string) (time.Time, error) {
 }
 
 func (s *S3Storage) Url(file string) (string, error) {
-	return strings.TrimSuffix(s.disk.Url, "/") + "/" + strings.TrimPrefix(file, "/"), nil
+	return s.UrlContext(context.Background(), file)
+}
+
+func (s *S3Storage) UrlContext(ctx context.Context, file string) (string, error) {
+	return joinUrl(s.disk.Url, cleanPath(file)), nil
+}
+
+// s3Reader implements io.ReadSeekCloser and io.ReaderAt for an S3 object
+type s3Reader struct {
+	ctx     context.Context
+	storage *S3Storage
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+	closed  bool
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.storage.getRange(r.ctx, r.key, r.offset, -1)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if body == nil {
+			return 0, io.EOF
+		}
+
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *s3Reader) ReadAt(p []byte, offset int64) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	data, err := r.storage.ReadRangeContext(r.ctx, r.key, offset, int64(len(p)))
+
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, data)
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	position, err := seekPosition(r.offset, r.size, offset, whence)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if position != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	r.offset = position
+
+	return position, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.closed {
+		return os.ErrClosed
+	}
+
+	r.closed = true
+
+	if r.body != nil {
+		return r.body.Close()
+	}
+
+	return nil
 }
 
 // toValidS3DirPath trims "./" and "/" prefixes/suffixes from a given path and
@@ -410,6 +1097,26 @@ func (s *S3Storage) toValidS3DirPath(path string) string {
 	return realPath
 }
 
+// s3Error maps an error returned by the S3 client to the matching
+// sentinel error, and wraps it in a *PathError. The original error
+// is kept in the chain, so the AWS error details are not lost.
+func s3Error(op, filePath string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr smithy.APIError
+
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NotFound", "NoSuchUpload":
+			err = fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+	}
+
+	return newPathError(op, filePath, err)
+}
+
 func fullPathOfFile(filePath string, source filesystem.File, name string) (string, error) {
 	extension := path.Ext(name)
 
@@ -0,0 +1,447 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestS3StoragePutStreamMultipart(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{
+		MultipartThreshold:   10,
+		MultipartPartSize:    5 * 1024 * 1024,
+		MultipartConcurrency: 2,
+	})
+
+	content := bytes.Repeat([]byte("0123456789"), 1200*1024) // 12 MB, 3 parts
+
+	err := storage.PutStream("dir/large.bin", bytes.NewReader(content), int64(len(content)))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fake.requestCount("UploadPart") != 3 {
+		t.Fatal("expected 3 parts, found:", fake.requestCount("UploadPart"))
+	}
+
+	if fake.requestCount("PutObject") != 0 {
+		t.Fatal("expected no PutObject requests, found:", fake.requestCount("PutObject"))
+	}
+
+	data, err := storage.ReadFile("dir/large.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Fatal("multipart upload content mismatch")
+	}
+}
+
+func TestS3StoragePutStreamBelowThreshold(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	err := storage.PutStream("small.txt", bytes.NewReader([]byte("small")), -1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fake.requestCount("PutObject") != 1 || fake.requestCount("UploadPart") != 0 {
+		t.Fatal("expected a single PutObject request")
+	}
+}
+
+func TestS3StorageResumableUpload(t *testing.T) {
+	storage, _ := newFakeS3Storage(t, Disk{})
+
+	uploadID, err := storage.InitiateUpload("video.mp4")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	first := bytes.Repeat([]byte("a"), 5*1024*1024)
+	second := []byte("tail")
+
+	if _, err := storage.UploadPart("video.mp4", uploadID, 1, bytes.NewReader(first), int64(len(first))); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// resume, the parts uploaded so far are known to S3
+	parts, err := storage.UploadedParts("video.mp4", uploadID)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(parts) != 1 || parts[0].PartNumber != 1 || parts[0].Size != int64(len(first)) {
+		t.Fatal("unexpected uploaded parts:", parts)
+	}
+
+	part, err := storage.UploadPart("video.mp4", uploadID, 2, bytes.NewReader(second), int64(len(second)))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// parts are sorted before completing
+	err = storage.CompleteUpload("video.mp4", uploadID, []UploadedPart{part, parts[0]})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err := storage.ReadFile("video.mp4")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(data, append(first, second...)) {
+		t.Fatal("resumed upload content mismatch")
+	}
+}
+
+func TestS3StorageAbortUpload(t *testing.T) {
+	storage, _ := newFakeS3Storage(t, Disk{})
+
+	uploadID, err := storage.InitiateUpload("aborted.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := storage.UploadPart("aborted.bin", uploadID, 1, bytes.NewReader([]byte("data")), 4); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storage.AbortUpload("aborted.bin", uploadID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = storage.UploadedParts("aborted.bin", uploadID)
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+
+	exists, err := storage.Exists("aborted.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if exists {
+		t.Fatal("aborted upload must not create the file")
+	}
+}
+
+func TestS3StorageClientReused(t *testing.T) {
+	storage, _ := newFakeS3Storage(t, Disk{})
+
+	first, err := storage.client()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	second, err := storage.client()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if first != second {
+		t.Fatal("expected the client to be reused")
+	}
+}
+
+func TestS3StorageSuppliedClient(t *testing.T) {
+	fakeStorage, fake := newFakeS3Storage(t, Disk{})
+
+	s3Client, err := fakeStorage.client()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	storage, err := NewStorage(Disk{
+		Driver:   DRIVER_S3,
+		Url:      "https://example.com",
+		Bucket:   "bucket",
+		S3Client: s3Client,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storage.Put("supplied.txt", []byte("supplied")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fake.requestCount("PutObject") != 1 {
+		t.Fatal("expected the supplied client to be used")
+	}
+}
+
+func TestS3StorageAWSConfigEndpoint(t *testing.T) {
+	disk := Disk{}
+	fake := newFakeS3(t, &disk)
+
+	// the endpoint comes from the config, and the url is only public
+	disk.Url = "https://cdn.invalid"
+
+	storage, err := NewStorage(disk)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storage.Put("config.txt", []byte("config")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fake.requestCount("PutObject") != 1 {
+		t.Fatal("expected the endpoint of the config to be used")
+	}
+
+	url, err := storage.Url("config.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !strings.HasPrefix(url, "https://cdn.invalid/") {
+		t.Fatal("unexpected url:", url)
+	}
+}
+
+func TestS3StorageEndpointOverridesAWSConfig(t *testing.T) {
+	disk := Disk{}
+	fake := newFakeS3(t, &disk)
+
+	disk.Url = "https://cdn.invalid"
+	disk.Endpoint = *disk.AWSConfig.BaseEndpoint
+	disk.AWSConfig.BaseEndpoint = aws.String("https://unreachable.invalid")
+
+	storage, err := NewStorage(disk)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storage.Put("endpoint.txt", []byte("endpoint")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fake.requestCount("PutObject") != 1 {
+		t.Fatal("expected the endpoint of the disk to be used")
+	}
+}
+
+func TestS3StorageListingPaginated(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	for i := 0; i < 1005; i++ {
+		fake.objects[fmt.Sprintf("dir/file%04d.txt", i)] = fakeS3Object{data: []byte("x")}
+	}
+
+	files, err := storage.Files("dir")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(files) != 1005 {
+		t.Fatal("expected 1005 files, found:", len(files))
+	}
+
+	if fake.requestCount("ListObjectsV2") != 2 {
+		t.Fatal("expected 2 list requests, found:", fake.requestCount("ListObjectsV2"))
+	}
+}
+
+func TestS3StorageDeleteDirectoryKeepsPrefix(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	for i := 0; i < 1005; i++ {
+		fake.objects[fmt.Sprintf("dir/file%04d.txt", i)] = fakeS3Object{data: []byte("x")}
+	}
+
+	fake.objects["other/keep.txt"] = fakeS3Object{data: []byte("keep")}
+
+	if err := storage.DeleteDirectory("dir"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(fake.objects) != 1 {
+		t.Fatal("expected only other/keep.txt to remain, found:", len(fake.objects))
+	}
+
+	if fake.requestCount("DeleteObjects") != 2 || fake.requestCount("DeleteObject") != 0 {
+		t.Fatal("expected 2 batched DeleteObjects requests, found:", fake.requestCount("DeleteObjects"))
+	}
+
+	if _, exists := fake.objects["other/keep.txt"]; !exists {
+		t.Fatal("expected other/keep.txt to remain")
+	}
+}
+
+func TestS3StorageDeleteFileBatches(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	files := []string{}
+
+	for i := 0; i < 2500; i++ {
+		key := fmt.Sprintf("file%04d.txt", i)
+		fake.objects[key] = fakeS3Object{data: []byte("x")}
+		files = append(files, key)
+	}
+
+	if err := storage.DeleteFile(files); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(fake.objects) != 0 {
+		t.Fatal("expected all files to be deleted, found:", len(fake.objects))
+	}
+
+	if fake.requestCount("DeleteObjects") != 3 {
+		t.Fatal("expected 3 DeleteObjects requests, found:", fake.requestCount("DeleteObjects"))
+	}
+}
+
+func TestS3StorageDeleteDirectoryReportsFailedKeys(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	fake.objects["dir/a.txt"] = fakeS3Object{data: []byte("a")}
+	fake.objects["dir/locked.txt"] = fakeS3Object{data: []byte("locked")}
+	fake.deleteErrors = map[string]string{"dir/locked.txt": "AccessDenied"}
+
+	err := storage.DeleteDirectory("dir")
+
+	if err == nil {
+		t.Fatal("expected an error for the failed key")
+	}
+
+	pathError := &PathError{}
+
+	if !errors.As(err, &pathError) || pathError.Path != "dir/locked.txt" {
+		t.Fatal("expected a *PathError for dir/locked.txt, found:", err)
+	}
+
+	if _, exists := fake.objects["dir/a.txt"]; exists {
+		t.Fatal("expected dir/a.txt to be deleted")
+	}
+}
+
+func TestS3StorageCopyDirectoryServerSide(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	for i := 0; i < 10; i++ {
+		if err := storage.Put(fmt.Sprintf("dir/%d.txt", i), []byte("test")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	if err := storage.CopyDirectory("dir", "copy"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fake.requestCount("CopyObject") != 10 {
+		t.Fatal("expected 10 CopyObject requests, found:", fake.requestCount("CopyObject"))
+	}
+
+	if fake.requestCount("GetObject") != 0 {
+		t.Fatal("expected no GetObject requests, found:", fake.requestCount("GetObject"))
+	}
+}
+
+func TestS3StorageCopyEscapesSource(t *testing.T) {
+	storage, _ := newFakeS3Storage(t, Disk{})
+
+	source := "dir/50% off & more?.txt"
+
+	if err := storage.Put(source, []byte("test")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storage.Copy(source, "copy/50% off & more?.txt"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err := storage.ReadFile("copy/50% off & more?.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "test" {
+		t.Fatal("unexpected content:", string(data))
+	}
+}
+
+func TestS3StorageMultipartPartSizeMinimum(t *testing.T) {
+	disk := NewFakeS3Disk(t)
+	disk.MultipartPartSize = 1024 * 1024
+
+	if _, err := NewStorage(disk); err == nil {
+		t.Fatal("expected an error for a part size below 5 MB")
+	}
+
+	disk.MultipartPartSize = 5 * 1024 * 1024
+
+	if _, err := NewStorage(disk); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestS3StorageStreamContextCanceled(t *testing.T) {
+	storage, fake := newFakeS3Storage(t, Disk{})
+
+	if err := storage.Put("test.txt", []byte("test")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := storage.PutStreamContext(ctx, "stream.txt", strings.NewReader("test"), 4)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+
+	_, err = storage.OpenReaderContext(ctx, "test.txt")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+
+	writer, err := storage.OpenWriterContext(ctx, "writer.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := writer.Write([]byte("test")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := writer.Close(); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+
+	if count := fake.requestCount("PutObject"); count != 1 {
+		t.Fatal("expected only the first put to be sent, got:", count)
+	}
+}
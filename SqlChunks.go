@@ -0,0 +1,429 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gouniverse/sb"
+	"github.com/gouniverse/sqlfilestore"
+)
+
+// the columns of the chunk table
+const (
+	sqlChunkColumnID     = "id"
+	sqlChunkColumnFileID = "file_id"
+	sqlChunkColumnOffset = "chunk_offset"
+	sqlChunkColumnSize   = "chunk_size"
+	sqlChunkColumnData   = "data"
+)
+
+// maxChunkOffset is the end of the range, when reading whole files
+const maxChunkOffset = math.MaxInt64
+
+// chunkTableCreate returns the SQL creating the chunk table. Each chunk
+// keeps its offset, so the chunk size can be changed for new files,
+// without breaking the existing ones.
+func (s *SQLStorage) chunkTableCreate() string {
+	return sb.NewBuilder(s.dbDriverName).
+		Table(s.ChunkTable).
+		Column(sb.Column{
+			Name:       sqlChunkColumnID,
+			Type:       sb.COLUMN_TYPE_STRING,
+			Length:     64,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name:   sqlChunkColumnFileID,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name: sqlChunkColumnOffset,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: sqlChunkColumnSize,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: sqlChunkColumnData,
+			Type: sb.COLUMN_TYPE_BLOB,
+		}).
+		CreateIfNotExists()
+}
+
+// chunkID returns the primary key of the chunk. The offset is zero padded,
+// so the chunks of a file are a range of the primary key, in offset order.
+func chunkID(fileID string, offset int64) string {
+	return fmt.Sprintf("%s:%020d", fileID, offset)
+}
+
+// chunkIDRange returns the bounds of the primary keys of the chunks of the file
+func chunkIDRange(fileID string) (first string, after string) {
+	// ";" follows ":" in ASCII
+	return fileID + ":", fileID + ";"
+}
+
+// putChunks stores the contents of the reader as the chunks of the file
+// record, and sets its size. The chunks of an existing file are replaced.
+// It runs in the transaction of the write, so a failed write leaves the
+// previous chunks in place.
+func (s *SQLStorage) putChunks(ctx context.Context, file *sqlfilestore.Record, exists bool, reader io.Reader) error {
+	if exists {
+		if err := s.deleteChunks(ctx, file.ID()); err != nil {
+			return err
+		}
+	}
+
+	size, err := s.writeChunks(ctx, file.ID(), reader)
+
+	if err != nil {
+		return err
+	}
+
+	file.SetContents("")
+	file.SetSize(fmt.Sprint(size))
+
+	return nil
+}
+
+// writeChunks reads the reader to the end, and stores
+// it in chunks of the chunk size, returning the size
+func (s *SQLStorage) writeChunks(ctx context.Context, fileID string, reader io.Reader) (int64, error) {
+	buffer := make([]byte, s.ChunkSize)
+	offset := int64(0)
+
+	for {
+		n, err := io.ReadFull(reader, buffer)
+
+		if n > 0 {
+			if err := s.insertChunk(ctx, fileID, offset, buffer[:n]); err != nil {
+				return offset, err
+			}
+
+			offset += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return offset, nil
+		}
+
+		if err != nil {
+			return offset, err
+		}
+	}
+}
+
+func (s *SQLStorage) insertChunk(ctx context.Context, fileID string, offset int64, data []byte) error {
+	sqlStr, params, err := goqu.Dialect(s.dbDriverName).
+		Insert(s.ChunkTable).
+		Prepared(true).
+		Rows(goqu.Record{
+			sqlChunkColumnID:     chunkID(fileID, offset),
+			sqlChunkColumnFileID: fileID,
+			sqlChunkColumnOffset: offset,
+			sqlChunkColumnSize:   len(data),
+			sqlChunkColumnData:   data,
+		}).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if s.DebugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = s.executor().ExecContext(ctx, sqlStr, params...)
+
+	return err
+}
+
+// readChunks reads the bytes from start to end (exclusive) of the file,
+// selecting only the chunks overlapping the range. It returns false,
+// if the file has no chunks in the range.
+func (s *SQLStorage) readChunks(ctx context.Context, fileID string, start, end int64) ([]byte, bool, error) {
+	first, after := chunkIDRange(fileID)
+
+	sqlStr, params, err := goqu.Dialect(s.dbDriverName).
+		From(s.ChunkTable).
+		Prepared(true).
+		Select(goqu.C(sqlChunkColumnOffset), goqu.C(sqlChunkColumnData)).
+		Where(
+			goqu.C(sqlChunkColumnID).Gte(first),
+			goqu.C(sqlChunkColumnID).Lt(after),
+			goqu.C(sqlChunkColumnFileID).Eq(fileID),
+			goqu.C(sqlChunkColumnOffset).Lt(end),
+			goqu.L("? + ?", goqu.C(sqlChunkColumnOffset), goqu.C(sqlChunkColumnSize)).Gt(start),
+		).
+		Order(goqu.C(sqlChunkColumnOffset).Asc()).
+		ToSQL()
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if s.DebugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := s.executor().QueryContext(ctx, sqlStr, params...)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer rows.Close()
+
+	found := false
+	content := []byte{}
+
+	for rows.Next() {
+		var offset int64
+		var data []byte
+
+		if err := rows.Scan(&offset, &data); err != nil {
+			return nil, false, err
+		}
+
+		found = true
+
+		from := max(start-offset, 0)
+		to := min(end-offset, int64(len(data)))
+
+		if from < to {
+			content = append(content, data[from:to]...)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	return content, found, nil
+}
+
+// copyChunks copies the chunks of the file to another file, one at a time
+func (s *SQLStorage) copyChunks(ctx context.Context, fromFileID, toFileID string) error {
+	offset := int64(0)
+
+	for {
+		data, found, err := s.readChunks(ctx, fromFileID, offset, offset+int64(s.ChunkSize))
+
+		if err != nil {
+			return err
+		}
+
+		if !found || len(data) == 0 {
+			return nil
+		}
+
+		if err := s.insertChunk(ctx, toFileID, offset, data); err != nil {
+			return err
+		}
+
+		offset += int64(len(data))
+	}
+}
+
+// deleteChunks deletes the chunks of the file, selected by the range
+// of their primary keys, as the file_id column is not indexed
+func (s *SQLStorage) deleteChunks(ctx context.Context, fileID string) error {
+	first, after := chunkIDRange(fileID)
+
+	sqlStr, params, err := goqu.Dialect(s.dbDriverName).
+		Delete(s.ChunkTable).
+		Prepared(true).
+		Where(
+			goqu.C(sqlChunkColumnID).Gte(first),
+			goqu.C(sqlChunkColumnID).Lt(after),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if s.DebugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = s.executor().ExecContext(ctx, sqlStr, params...)
+
+	return err
+}
+
+// MigrateContentsToChunks moves the contents of the files stored base64
+// encoded in the contents column to the chunk table, one file at a time.
+// Until migrated, the files are still read from the contents column, so
+// the migration can run while the storage is in use, and can be resumed.
+func (s *SQLStorage) MigrateContentsToChunks() error {
+	return s.MigrateContentsToChunksContext(context.Background())
+}
+
+func (s *SQLStorage) MigrateContentsToChunksContext(ctx context.Context) error {
+	if !s.ChunkedContents {
+		return errors.New("chunked contents are not enabled")
+	}
+
+	sqlStr, params, err := goqu.Dialect(s.dbDriverName).
+		From(s.FilestoreTable).
+		Prepared(true).
+		Select(goqu.C(sqlfilestore.COLUMN_ID)).
+		Where(
+			goqu.C(sqlfilestore.COLUMN_TYPE).Eq(sqlfilestore.TYPE_FILE),
+			goqu.C(sqlfilestore.COLUMN_CONTENTS).Neq(""),
+			goqu.C(sqlfilestore.COLUMN_DELETED_AT).Eq(sb.NULL_DATETIME),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if s.DebugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := s.executor().QueryContext(ctx, sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := s.transaction(ctx, nil, func(tx *SQLStorage) error {
+			return tx.migrateFileToChunks(ctx, id)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateFileToChunks writes the chunks of a file, and then empties its
+// contents column. The chunks of an earlier, interrupted, run are replaced.
+func (s *SQLStorage) migrateFileToChunks(ctx context.Context, fileID string) error {
+	record, err := s.recordFindByID(ctx, fileID, sqlfilestore.RecordQueryOptions{
+		Columns: []string{sqlfilestore.COLUMN_ID, sqlfilestore.COLUMN_CONTENTS},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if record == nil || record.Contents() == "" {
+		return nil
+	}
+
+	content, err := base64.StdEncoding.DecodeString(record.Contents())
+
+	if err != nil {
+		return newPathError("migrate", fileID, err)
+	}
+
+	if err := s.deleteChunks(ctx, fileID); err != nil {
+		return err
+	}
+
+	if _, err := s.writeChunks(ctx, fileID, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	sqlStr, params, err := goqu.Dialect(s.dbDriverName).
+		Update(s.FilestoreTable).
+		Prepared(true).
+		Set(goqu.Record{sqlfilestore.COLUMN_CONTENTS: ""}).
+		Where(goqu.C(sqlfilestore.COLUMN_ID).Eq(fileID)).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if s.DebugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = s.executor().ExecContext(ctx, sqlStr, params...)
+
+	return err
+}
+
+// sqlChunkReader reads a chunked file sequentially, a chunk at a time
+type sqlChunkReader struct {
+	ctx     context.Context
+	storage *SQLStorage
+	fileID  string
+	offset  int64
+	buffer  []byte
+	closed  bool
+}
+
+func (r *sqlChunkReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	if len(r.buffer) == 0 {
+		data, _, err := r.storage.readChunks(r.ctx, r.fileID, r.offset, r.offset+int64(r.storage.ChunkSize))
+
+		if err != nil {
+			return 0, err
+		}
+
+		if len(data) == 0 {
+			return 0, io.EOF
+		}
+
+		r.buffer = data
+		r.offset += int64(len(data))
+	}
+
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+
+	return n, nil
+}
+
+func (r *sqlChunkReader) Close() error {
+	r.closed = true
+	r.buffer = nil
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package filesystem
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// sqlExecutorConnector opens connections running their statements with an
+// executor, and a context. sqlfilestore only runs its statements against a
+// *sql.DB, so a database opened with the connector lets it run them in the
+// transaction of the storage, and with the context of the operation.
+type sqlExecutorConnector struct {
+	ctx      context.Context
+	executor sqlExecutor
+}
+
+// openExecutorDB opens a database running its statements with the executor,
+// which is closed by the caller when done
+func openExecutorDB(ctx context.Context, executor sqlExecutor) *sql.DB {
+	return sql.OpenDB(sqlExecutorConnector{ctx: ctx, executor: executor})
+}
+
+func (c sqlExecutorConnector) Connect(context.Context) (driver.Conn, error) {
+	return sqlExecutorConn(c), nil
+}
+
+func (c sqlExecutorConnector) Driver() driver.Driver {
+	return sqlExecutorDriver{}
+}
+
+// sqlExecutorDriver is the driver of the connector, which can not
+// open connections by name
+type sqlExecutorDriver struct{}
+
+func (sqlExecutorDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("executor connections are only opened by the connector")
+}
+
+// sqlExecutorConn runs the statements with the executor. The statements
+// are not prepared, and the transactions are those of the executor.
+type sqlExecutorConn sqlExecutorConnector
+
+func (c sqlExecutorConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("executor connections do not prepare statements")
+}
+
+func (c sqlExecutorConn) Close() error {
+	return nil
+}
+
+func (c sqlExecutorConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("executor connections do not begin transactions")
+}
+
+func (c sqlExecutorConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.executor.ExecContext(c.ctx, query, namedValues(args)...)
+}
+
+func (c sqlExecutorConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.executor.QueryContext(c.ctx, query, namedValues(args)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := rows.Columns()
+
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	return &sqlExecutorRows{rows: rows, columns: columns}, nil
+}
+
+// namedValues returns the values of the arguments
+func namedValues(args []driver.NamedValue) []any {
+	values := make([]any, len(args))
+
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
+
+// sqlExecutorRows passes on the rows of the executor, with every value
+// converted to the string sqlfilestore keeps
+type sqlExecutorRows struct {
+	rows    *sql.Rows
+	columns []string
+}
+
+func (r *sqlExecutorRows) Columns() []string {
+	return r.columns
+}
+
+func (r *sqlExecutorRows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *sqlExecutorRows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+
+		return io.EOF
+	}
+
+	values := make([]any, len(dest))
+	pointers := make([]any, len(dest))
+
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	if err := r.rows.Scan(pointers...); err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		if value == nil {
+			dest[i] = nil
+			continue
+		}
+
+		dest[i] = sqlValueString(value)
+	}
+
+	return nil
+}
+
+// sqlValueString converts a scanned value to the string kept in a record,
+// as sqlfilestore keeps it. The drivers scan the same column as different
+// types (i.e. MySQL with parseTime scans a DATETIME as a time.Time), so
+// every type is converted explicitly, rather than with its default format.
+func sqlValueString(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case time.Time:
+		// in UTC, as stored, in the format of sb.NULL_DATETIME
+		return value.UTC().Format(time.DateTime)
+	default:
+		return fmt.Sprint(value)
+	}
+}
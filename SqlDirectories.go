@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/gouniverse/sqlfilestore"
+)
+
+// sqlWriteAttempts is the number of attempts to create the missing
+// directories, or to put a file, as a serializable transaction may
+// fail on a conflict
+const sqlWriteAttempts = 3
+
+// MakeDirectoryAll creates the directory together with any missing
+// parents, as mkdir -p. An existing directory is not an error.
+func (s *SQLStorage) MakeDirectoryAll(directoryPath string) error {
+	return s.MakeDirectoryAllContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) MakeDirectoryAllContext(ctx context.Context, directoryPath string) error {
+	_, err := s.makeDirectoryAll(ctx, "mkdir", directoryPath)
+	return err
+}
+
+// makeDirectoryAll creates the missing directories of the path, and
+// returns the record of the directory. The directories are created in a
+// serializable transaction, and the writers of this storage take turns,
+// so concurrent writers do not create the same directory twice. A failed
+// transaction is retried, as the directories are found on the next attempt.
+// Within WithTx the directories are created in its transaction instead.
+func (s *SQLStorage) makeDirectoryAll(ctx context.Context, op, directoryPath string) (*sqlfilestore.Record, error) {
+	if s.tx != nil {
+		return s.makeDirectories(ctx, op, directoryPath)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var err error
+
+	for attempt := 0; attempt < sqlWriteAttempts; attempt++ {
+		var directory *sqlfilestore.Record
+
+		err = s.transaction(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *SQLStorage) (err error) {
+			directory, err = tx.makeDirectories(ctx, op, directoryPath)
+			return err
+		})
+
+		if err == nil {
+			return directory, nil
+		}
+
+		var pathErr *PathError
+
+		// a path error (i.e. a file in the way) fails the same way again
+		if errors.As(err, &pathErr) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// makeDirectories walks the path from the root, creating the missing directories
+func (s *SQLStorage) makeDirectories(ctx context.Context, op, directoryPath string) (*sqlfilestore.Record, error) {
+	parent, err := s.findDirectory(ctx, op, ROOT_PATH)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parent == nil {
+		return nil, newPathError(op, directoryPath, errors.New("root directory not found"))
+	}
+
+	currentPath := ""
+
+	for _, name := range strings.Split(cleanPath(directoryPath), PATH_SEPARATOR) {
+		if name == "" {
+			continue
+		}
+
+		currentPath += PATH_SEPARATOR + name
+
+		directory, err := s.findDirectory(ctx, op, currentPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if directory == nil {
+			directory = sqlfilestore.NewDirectory().
+				SetParentID(parent.ID()).
+				SetName(name).
+				SetPath(currentPath)
+
+			if err := s.recordCreate(ctx, directory); err != nil {
+				return nil, err
+			}
+		}
+
+		parent = directory
+	}
+
+	return parent, nil
+}
+
+// findDirectory finds the directory by path. It returns nil when
+// the path does not exist, and ErrNotDirectory when the path is a file.
+func (s *SQLStorage) findDirectory(ctx context.Context, op, directoryPath string) (*sqlfilestore.Record, error) {
+	record, err := s.recordFindByPath(ctx, directoryPath, sqlfilestore.RecordQueryOptions{
+		Columns: []string{
+			sqlfilestore.COLUMN_ID,
+			sqlfilestore.COLUMN_TYPE,
+			sqlfilestore.COLUMN_PATH,
+		},
+	})
+
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	if !record.IsDirectory() {
+		return nil, newPathError(op, cleanPath(directoryPath), ErrNotDirectory)
+	}
+
+	return record, nil
+}
+
+// CopyDirectory copies the directory and all its contents, recursively,
+// in a transaction, so either the whole tree is copied or nothing is
+func (s *SQLStorage) CopyDirectory(sourceDir, targetDir string) error {
+	return s.CopyDirectoryWithOptionsContext(context.Background(), sourceDir, targetDir, CopyDirectoryOptions{})
+}
+
+func (s *SQLStorage) CopyDirectoryContext(ctx context.Context, sourceDir, targetDir string) error {
+	return s.CopyDirectoryWithOptionsContext(ctx, sourceDir, targetDir, CopyDirectoryOptions{})
+}
+
+// CopyDirectoryWithOptions copies the directory as CopyDirectory does, with the options
+func (s *SQLStorage) CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	return s.CopyDirectoryWithOptionsContext(context.Background(), sourceDir, targetDir, options)
+}
+
+func (s *SQLStorage) CopyDirectoryWithOptionsContext(ctx context.Context, sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.transaction(ctx, nil, func(tx *SQLStorage) error {
+		makeDirectory := func(dir string) error {
+			_, err := tx.makeDirectoryAll(ctx, "copy", dir)
+			return err
+		}
+
+		copyFile := func(source, target string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return tx.copy(ctx, source, target)
+		}
+
+		return copyDirectory(tx, sourceDir, targetDir, options, makeDirectory, copyFile)
+	})
+}
@@ -0,0 +1,200 @@
+package filesystem
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gouniverse/sb"
+	"github.com/gouniverse/sqlfilestore"
+)
+
+// sqlExecutor runs the statements of the SQL storage. It is implemented
+// by both *sql.DB and *sql.Tx, so the same code runs in and out of a
+// transaction.
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// executor returns the transaction the storage is bound to, if any, otherwise the database
+func (s *SQLStorage) executor() sqlExecutor {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.DB
+}
+
+// records runs fn with a sqlfilestore store, which runs its statements
+// with the executor of the storage, and the context
+func (s *SQLStorage) records(ctx context.Context, fn func(store *sqlfilestore.Store, db *sql.DB) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db := openExecutorDB(ctx, s.executor())
+	defer db.Close()
+
+	store, err := sqlfilestore.NewStore(sqlfilestore.NewStoreOptions{
+		TableName:    s.FilestoreTable,
+		DB:           db,
+		DbDriverName: s.dbDriverName,
+		DebugEnabled: s.DebugEnabled,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return fn(store, db)
+}
+
+// The record methods below run the ones of the sqlfilestore store
+// with the executor, and the context, of the storage.
+
+func (s *SQLStorage) recordList(ctx context.Context, options sqlfilestore.RecordQueryOptions) (list []sqlfilestore.Record, err error) {
+	err = s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		list, err = store.RecordList(options)
+		return err
+	})
+
+	return list, err
+}
+
+// recordListAfterPath lists the records in the directory, in path order,
+// with a path after the cursor, as sqlfilestore only lists by offset
+func (s *SQLStorage) recordListAfterPath(ctx context.Context, parentID, cursor string, limit int, columns []string) (list []sqlfilestore.Record, err error) {
+	q := goqu.Dialect(s.dbDriverName).
+		From(s.FilestoreTable).
+		Prepared(true).
+		Where(
+			goqu.C(sqlfilestore.COLUMN_PARENT_ID).Eq(parentID),
+			goqu.C(sqlfilestore.COLUMN_DELETED_AT).Eq(sb.NULL_DATETIME),
+		).
+		Order(goqu.C(sqlfilestore.COLUMN_PATH).Asc()).
+		Limit(uint(limit))
+
+	if cursor != "" {
+		q = q.Where(goqu.C(sqlfilestore.COLUMN_PATH).Gt(cursor))
+	}
+
+	selected := make([]any, len(columns))
+
+	for i, column := range columns {
+		selected[i] = goqu.C(column)
+	}
+
+	sqlStr, params, err := q.Select(selected...).ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.records(ctx, func(_ *sqlfilestore.Store, db *sql.DB) error {
+		database := sb.NewDatabase(db, s.dbDriverName)
+		database.DebugEnable(s.DebugEnabled)
+
+		rows, err := database.SelectToMapString(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+
+		list = make([]sqlfilestore.Record, len(rows))
+
+		for i, row := range rows {
+			list[i] = *sqlfilestore.NewRecordFromExistingData(row)
+		}
+
+		return nil
+	})
+
+	return list, err
+}
+
+func (s *SQLStorage) recordFindByPath(ctx context.Context, recordPath string, options sqlfilestore.RecordQueryOptions) (record *sqlfilestore.Record, err error) {
+	err = s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		record, err = store.RecordFindByPath(recordPath, options)
+		return err
+	})
+
+	return record, err
+}
+
+func (s *SQLStorage) recordFindByID(ctx context.Context, id string, options sqlfilestore.RecordQueryOptions) (record *sqlfilestore.Record, err error) {
+	err = s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		record, err = store.RecordFindByID(id, options)
+		return err
+	})
+
+	return record, err
+}
+
+func (s *SQLStorage) recordCount(ctx context.Context, options sqlfilestore.RecordQueryOptions) (count int64, err error) {
+	err = s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		count, err = store.RecordCount(options)
+		return err
+	})
+
+	return count, err
+}
+
+func (s *SQLStorage) recordCreate(ctx context.Context, record *sqlfilestore.Record) error {
+	return s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		return store.RecordCreate(record)
+	})
+}
+
+func (s *SQLStorage) recordUpdate(ctx context.Context, record *sqlfilestore.Record) error {
+	return s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		return store.RecordUpdate(record)
+	})
+}
+
+// recordSoftDelete marks the record as deleted. The chunks of a file
+// are deleted with it, as a soft deleted file is never read again.
+func (s *SQLStorage) recordSoftDelete(ctx context.Context, record *sqlfilestore.Record) error {
+	err := s.records(ctx, func(store *sqlfilestore.Store, _ *sql.DB) error {
+		return store.RecordSoftDelete(record)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !s.ChunkedContents || !record.IsFile() {
+		return nil
+	}
+
+	return s.deleteChunks(ctx, record.ID())
+}
+
+// recordRecalculatePath sets the path of the record from its parent,
+// and then the paths of its children, recursively. The one of the
+// sqlfilestore store lists the children without their names.
+func (s *SQLStorage) recordRecalculatePath(ctx context.Context, record *sqlfilestore.Record, parent *sqlfilestore.Record) error {
+	record.SetPath(parent.Path() + PATH_SEPARATOR + record.Name())
+
+	if err := s.recordUpdate(ctx, record); err != nil {
+		return err
+	}
+
+	children, err := s.recordList(ctx, sqlfilestore.RecordQueryOptions{
+		ParentID: record.ID(),
+		Columns:  []string{sqlfilestore.COLUMN_ID, sqlfilestore.COLUMN_NAME, sqlfilestore.COLUMN_PATH},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for i := range children {
+		if err := s.recordRecalculatePath(ctx, &children[i], record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
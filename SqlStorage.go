@@ -1,28 +1,87 @@
 package filesystem
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/base64"
 	"errors"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/doug-martin/goqu/v9"
 	"github.com/dromara/carbon/v2"
 	"github.com/emirpasic/gods/utils"
 	"github.com/gouniverse/sb"
 	"github.com/gouniverse/sqlfilestore"
 )
 
-var _ StorageInterface = (*SQLStorage)(nil) // verify it extends the storage interface
+var _ StorageInterface = (*SQLStorage)(nil)        // verify it extends the storage interface
+var _ StorageContextInterface = (*SQLStorage)(nil) // verify it extends the storage context interface
+var _ StorageStreamInterface = (*SQLStorage)(nil)  // verify it extends the storage stream interface
+var _ StorageRangeInterface = (*SQLStorage)(nil)   // verify it extends the storage range interface
+var _ StoragePageInterface = (*SQLStorage)(nil)    // verify it extends the storage page interface
+var _ StorageWalkInterface = (*SQLStorage)(nil)    // verify it extends the storage walk interface
+var _ StorageStatInterface = (*SQLStorage)(nil)    // verify it extends the storage stat interface
+var _ StoragePutInterface = (*SQLStorage)(nil)     // verify it extends the storage put interface
+var _ StorageCopyInterface = (*SQLStorage)(nil)    // verify it extends the storage copy interface
+
+// sqlDriver creates the storage of a DRIVER_SQL disk. The DB is
+// set in code, so it is not checked by the validation.
+func sqlDriver(disk Disk) (StorageInterface, error) {
+	return NewSqlStorage(SqlStorageOptions{
+		DB:                 disk.DB,
+		FilestoreTable:     disk.TableName,
+		AutomigrateEnabled: true,
+		URL:                disk.Url,
+		Visibility:         disk.Visibility,
+		ChunkedContents:    disk.ChunkedContents,
+		ChunkSize:          disk.ChunkSize,
+	})
+}
+
+func validateSqlDisk(disk Disk) error {
+	if disk.TableName == "" {
+		return errors.New("table name is required field")
+	}
 
+	return nil
+}
+
+// SQLStorage implements the StorageInterface on top of a database table,
+// using the sqlfilestore package. As the file store does not accept a
+// context, the context-aware methods check the context before each
+// database statement, instead of cancelling a running one.
+//
+// By default the contents are stored base64 encoded in the contents
+// column. With ChunkedContents, they are stored as BLOB chunks in the
+// chunk table instead, which allows streaming and ranged reads and writes,
+// and files larger than the maximum packet size of the database.
 type SQLStorage struct {
 	DB                 *sql.DB
 	FilestoreTable     string
 	URL                string
+	Visibility         string
 	AutomigrateEnabled bool
 	DebugEnabled       bool
-	store              *sqlfilestore.Store
+	ChunkedContents    bool
+	ChunkSize          int
+	ChunkTable         string
+
+	RequireParentDirectories bool
+	MakeDirectoryParents     bool
+
+	dbDriverName string
+	store        *sqlfilestore.Store // only used for the automigration
+	writeMu      *sync.Mutex         // serializes the writes creating files and directories
+	tx           *sql.Tx             // the transaction the storage runs in, if any
 }
 
 type SqlStorageOptions struct {
@@ -31,6 +90,28 @@ type SqlStorageOptions struct {
 	URL                string
 	AutomigrateEnabled bool
 	DebugEnabled       bool
+
+	// Visibility is the visibility of the files, as returned by Stat and
+	// List. The table has no visibility column, and all the files are
+	// served from URL, so it is the same for all of them. Defaults to
+	// VISIBILITY_PUBLIC.
+	Visibility string
+
+	// ChunkedContents stores the contents of new files in the chunk table.
+	// The existing files are still read from the contents column, until
+	// moved with MigrateContentsToChunks. Once enabled, it is not meant
+	// to be disabled again, as the chunked files are read from the chunks.
+	ChunkedContents bool
+	ChunkSize       int    // defaults to DEFAULT_SQL_CHUNK_SIZE
+	ChunkTable      string // defaults to FilestoreTable + "_chunk"
+
+	// RequireParentDirectories fails Put with ErrNotFound, when the parent
+	// directory is missing. By default, the missing directories are created.
+	RequireParentDirectories bool
+
+	// MakeDirectoryParents creates the missing parents in MakeDirectory,
+	// as mkdir -p, instead of failing with ErrNotFound
+	MakeDirectoryParents bool
 }
 
 func NewSqlStorage(options SqlStorageOptions) (*SQLStorage, error) {
@@ -46,8 +127,27 @@ func NewSqlStorage(options SqlStorageOptions) (*SQLStorage, error) {
 		DB:                 options.DB,
 		FilestoreTable:     options.FilestoreTable,
 		URL:                options.URL,
+		Visibility:         options.Visibility,
 		AutomigrateEnabled: options.AutomigrateEnabled,
 		DebugEnabled:       options.DebugEnabled,
+		ChunkedContents:    options.ChunkedContents,
+		ChunkSize:          options.ChunkSize,
+		ChunkTable:         options.ChunkTable,
+
+		RequireParentDirectories: options.RequireParentDirectories,
+		MakeDirectoryParents:     options.MakeDirectoryParents,
+	}
+
+	if storage.Visibility == "" {
+		storage.Visibility = VISIBILITY_PUBLIC
+	}
+
+	if storage.ChunkSize <= 0 {
+		storage.ChunkSize = DEFAULT_SQL_CHUNK_SIZE
+	}
+
+	if storage.ChunkTable == "" {
+		storage.ChunkTable = storage.FilestoreTable + "_chunk"
 	}
 
 	err := storage.init()
@@ -60,6 +160,9 @@ func NewSqlStorage(options SqlStorageOptions) (*SQLStorage, error) {
 }
 
 func (s *SQLStorage) init() (err error) {
+	s.dbDriverName = sb.DatabaseDriverName(s.DB)
+	s.writeMu = &sync.Mutex{}
+
 	s.store, err = sqlfilestore.NewStore(sqlfilestore.NewStoreOptions{
 		DB:                 s.DB,
 		TableName:          s.FilestoreTable,
@@ -71,19 +174,25 @@ func (s *SQLStorage) init() (err error) {
 		return err
 	}
 
+	if s.AutomigrateEnabled && s.ChunkedContents {
+		if _, err := s.DB.Exec(s.chunkTableCreate()); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (s *SQLStorage) findParentDirectoryFromPath(path string) (*sqlfilestore.Record, error) {
-	targetPathParts := strings.Split(path, PATH_SEPARATOR)
+func (s *SQLStorage) findParentDirectoryFromPath(ctx context.Context, filePath string) (*sqlfilestore.Record, error) {
+	filePath = s.fixPath(filePath)
 
-	if len(targetPathParts) < 1 {
+	if filePath == ROOT_PATH {
 		return nil, errors.New("invalid path")
 	}
 
-	targetDirPath := ROOT_PATH + strings.Join(targetPathParts[:len(targetPathParts)-1], PATH_SEPARATOR)
+	targetDirPath := path.Dir(filePath)
 
-	targetDirectory, err := s.store.RecordFindByPath(targetDirPath, sqlfilestore.RecordQueryOptions{})
+	targetDirectory, err := s.recordFindByPath(ctx, targetDirPath, sqlfilestore.RecordQueryOptions{})
 
 	if err != nil {
 		return nil, err
@@ -97,35 +206,81 @@ func (s *SQLStorage) findParentDirectoryFromPath(path string) (*sqlfilestore.Rec
 }
 
 func (s *SQLStorage) Copy(originFilePath, targetFilePath string) error {
-	record, err := s.store.RecordFindByPath(originFilePath, sqlfilestore.RecordQueryOptions{})
+	return s.CopyContext(context.Background(), originFilePath, targetFilePath)
+}
+
+func (s *SQLStorage) CopyContext(ctx context.Context, originFilePath, targetFilePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.transaction(ctx, nil, func(tx *SQLStorage) error {
+		return tx.copy(ctx, originFilePath, targetFilePath)
+	})
+}
+
+// copy copies the file record, and its chunks. An existing
+// target file is replaced, as on the other storages.
+func (s *SQLStorage) copy(ctx context.Context, originFilePath, targetFilePath string) error {
+	record, err := s.recordFindByPath(ctx, s.fixPath(originFilePath), sqlfilestore.RecordQueryOptions{})
 
 	if err != nil {
 		return err
 	}
 
 	if record == nil {
-		return errors.New("path not found")
+		return newPathError("copy", originFilePath, ErrNotFound)
 	}
 
 	if !record.IsFile() {
-		return errors.New("not a file")
+		return newPathError("copy", originFilePath, ErrNotFile)
 	}
 
-	targetDirectory, err := s.findParentDirectoryFromPath(targetFilePath)
+	targetDirectory, err := s.findParentDirectoryFromPath(ctx, targetFilePath)
 
 	if err != nil {
 		return err
 	}
 
+	if targetDirectory == nil {
+		return newPathError("copy", targetFilePath, ErrNotFound)
+	}
+
+	existing, err := s.recordFindByPath(ctx, s.fixPath(targetFilePath), sqlfilestore.RecordQueryOptions{
+		Columns: []string{sqlfilestore.COLUMN_ID, sqlfilestore.COLUMN_TYPE},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if existing != nil && !existing.IsFile() {
+		return newPathError("copy", targetFilePath, ErrNotFile)
+	}
+
+	if existing != nil {
+		if err := s.recordSoftDelete(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	targetName := s.findFileName(targetFilePath)
+
 	file := sqlfilestore.NewFile().
 		SetParentID(targetDirectory.ID()).
-		SetName(record.Name()).
+		SetName(targetName).
 		SetContents(record.Contents()).
 		SetSize(record.Size()).
-		SetExtension(record.Extension()).
-		SetPath(targetDirectory.Path() + PATH_SEPARATOR + record.Name())
+		SetExtension(s.findExtension(targetName)).
+		SetPath(targetDirectory.Path() + PATH_SEPARATOR + targetName)
 
-	err = s.store.RecordCreate(file)
+	if s.ChunkedContents && record.Contents() == "" {
+		if err := s.copyChunks(ctx, record.ID(), file.ID()); err != nil {
+			return err
+		}
+	}
+
+	err = s.recordCreate(ctx, file)
 
 	if err != nil {
 		return err
@@ -135,8 +290,28 @@ func (s *SQLStorage) Copy(originFilePath, targetFilePath string) error {
 }
 
 func (s *SQLStorage) DeleteFile(filePaths []string) error {
+	return s.DeleteFileContext(context.Background(), filePaths)
+}
+
+func (s *SQLStorage) DeleteFileContext(ctx context.Context, filePaths []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.transaction(ctx, nil, func(tx *SQLStorage) error {
+		return tx.deleteFiles(ctx, filePaths)
+	})
+}
+
+// deleteFiles soft deletes the files, and the directories recursively
+func (s *SQLStorage) deleteFiles(ctx context.Context, filePaths []string) error {
+
 	for _, filePath := range filePaths {
-		record, err := s.store.RecordFindByPath(filePath, sqlfilestore.RecordQueryOptions{
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{
 			Columns: []string{
 				sqlfilestore.COLUMN_ID,
 				sqlfilestore.COLUMN_TYPE,
@@ -153,7 +328,7 @@ func (s *SQLStorage) DeleteFile(filePaths []string) error {
 		}
 
 		if record.IsDirectory() {
-			err = s.DeleteDirectory(record.Path())
+			err = s.deleteDirectory(ctx, record.Path())
 
 			if err != nil {
 				return err
@@ -163,21 +338,48 @@ func (s *SQLStorage) DeleteFile(filePaths []string) error {
 		}
 
 		if record.IsFile() {
-			err = s.store.RecordSoftDelete(record)
+			err = s.recordSoftDelete(ctx, record)
 
 			if err != nil {
 				return err
 			}
+
+			continue
 		}
 
-		return errors.New("not a file or directory: " + record.Path())
+		return newPathError("delete", filePath, ErrNotFile)
 	}
 	return nil
 }
 
 // DeleteDirectory deletes a directory
 func (s *SQLStorage) DeleteDirectory(directoryPath string) error {
-	file, err := s.store.RecordFindByPath(directoryPath, sqlfilestore.RecordQueryOptions{
+	return s.DeleteDirectoryContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) DeleteDirectoryContext(ctx context.Context, directoryPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.transaction(ctx, nil, func(tx *SQLStorage) error {
+		return tx.deleteDirectory(ctx, directoryPath)
+	})
+}
+
+// deleteDirectory soft deletes the directory and its children, recursively
+func (s *SQLStorage) deleteDirectory(ctx context.Context, directoryPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	directoryPath = s.fixPath(directoryPath)
+
+	if directoryPath == ROOT_PATH {
+		return newPathError("delete", directoryPath, errors.New("can not delete the root directory"))
+	}
+
+	file, err := s.recordFindByPath(ctx, directoryPath, sqlfilestore.RecordQueryOptions{
 		Columns: []string{
 			sqlfilestore.COLUMN_ID,
 			sqlfilestore.COLUMN_TYPE,
@@ -193,10 +395,10 @@ func (s *SQLStorage) DeleteDirectory(directoryPath string) error {
 	}
 
 	if !file.IsDirectory() {
-		return errors.New("not a directory")
+		return newPathError("delete", directoryPath, ErrNotDirectory)
 	}
 
-	children, err := s.store.RecordList(sqlfilestore.RecordQueryOptions{
+	children, err := s.recordList(ctx, sqlfilestore.RecordQueryOptions{
 		ParentID: file.ID(),
 		Columns: []string{
 			sqlfilestore.COLUMN_ID,
@@ -210,8 +412,12 @@ func (s *SQLStorage) DeleteDirectory(directoryPath string) error {
 	}
 
 	for _, child := range children {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if child.IsDirectory() {
-			err = s.DeleteDirectory(child.Path())
+			err = s.deleteDirectory(ctx, child.Path())
 
 			if err != nil {
 				return err
@@ -220,33 +426,41 @@ func (s *SQLStorage) DeleteDirectory(directoryPath string) error {
 			continue
 		}
 
-		err = s.store.RecordSoftDelete(&child)
+		err = s.recordSoftDelete(ctx, &child)
 
 		if err != nil {
 			return err
 		}
 	}
 
-	err = s.store.RecordSoftDelete(file)
+	err = s.recordSoftDelete(ctx, file)
 
 	return err
 }
 
 // Directories lists the sub-directories in the specified directory
 func (s *SQLStorage) Directories(directoryPath string) ([]string, error) {
+	return s.DirectoriesContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) DirectoriesContext(ctx context.Context, directoryPath string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	directoryPath = s.fixPath(directoryPath)
 
-	dir, err := s.store.RecordFindByPath(directoryPath, sqlfilestore.RecordQueryOptions{Columns: []string{"id"}})
+	dir, err := s.recordFindByPath(ctx, directoryPath, sqlfilestore.RecordQueryOptions{Columns: []string{"id"}})
 
 	if err != nil {
 		return nil, err
 	}
 
 	if dir == nil {
-		return nil, errors.New("directory not found")
+		return nil, newPathError("list", directoryPath, ErrNotFound)
 	}
 
-	records, err := s.store.RecordList(sqlfilestore.RecordQueryOptions{
+	records, err := s.recordList(ctx, sqlfilestore.RecordQueryOptions{
 		ParentID: dir.ID(),
 		Type:     sqlfilestore.TYPE_DIRECTORY,
 	})
@@ -262,27 +476,37 @@ func (s *SQLStorage) Directories(directoryPath string) ([]string, error) {
 	paths := make([]string, len(records))
 
 	for i, record := range records {
-		paths[i] = record.Path()
+		paths[i] = cleanPath(record.Path())
 	}
 
+	sort.Strings(paths)
+
 	return paths, nil
 }
 
 // Files lists the files in the specified directory
 func (s *SQLStorage) Files(directoryPath string) ([]string, error) {
+	return s.FilesContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) FilesContext(ctx context.Context, directoryPath string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	directoryPath = s.fixPath(directoryPath)
 
-	dir, err := s.store.RecordFindByPath(directoryPath, sqlfilestore.RecordQueryOptions{Columns: []string{"id"}})
+	dir, err := s.recordFindByPath(ctx, directoryPath, sqlfilestore.RecordQueryOptions{Columns: []string{"id"}})
 
 	if err != nil {
 		return nil, err
 	}
 
 	if dir == nil {
-		return nil, errors.New("directory not found")
+		return nil, newPathError("list", directoryPath, ErrNotFound)
 	}
 
-	records, err := s.store.RecordList(sqlfilestore.RecordQueryOptions{
+	records, err := s.recordList(ctx, sqlfilestore.RecordQueryOptions{
 		ParentID: dir.ID(),
 		Type:     sqlfilestore.TYPE_FILE,
 	})
@@ -298,16 +522,271 @@ func (s *SQLStorage) Files(directoryPath string) ([]string, error) {
 	paths := make([]string, len(records))
 
 	for i, record := range records {
-		paths[i] = record.Path()
+		paths[i] = cleanPath(record.Path())
 	}
 
+	sort.Strings(paths)
+
 	return paths, nil
 }
 
+// ListPage lists a page of the entries of the directory. The page is
+// selected with the path of the last entry of the previous page, so
+// entries added or removed between the calls are not skipped or repeated.
+func (s *SQLStorage) ListPage(directoryPath string, cursor string, limit int) (Page, error) {
+	return s.ListPageContext(context.Background(), directoryPath, cursor, limit)
+}
+
+func (s *SQLStorage) ListPageContext(ctx context.Context, directoryPath string, cursor string, limit int) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+
+	if limit <= 0 {
+		limit = DEFAULT_PAGE_LIMIT
+	}
+
+	directoryPath = s.fixPath(directoryPath)
+
+	dir, err := s.recordFindByPath(ctx, directoryPath, sqlfilestore.RecordQueryOptions{Columns: []string{"id"}})
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	if dir == nil {
+		return Page{}, newPathError("list", directoryPath, ErrNotFound)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+
+	after := ""
+
+	if cursor != "" {
+		after = s.fixPath(cursor)
+	}
+
+	// one more record is fetched, to find if there is a next page
+	records, err := s.recordListAfterPath(ctx, dir.ID(), after, limit+1, []string{
+		sqlfilestore.COLUMN_ID,
+		sqlfilestore.COLUMN_TYPE,
+		sqlfilestore.COLUMN_PATH,
+	})
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Directories: []string{}, Files: []string{}}
+
+	if len(records) > limit {
+		records = records[:limit]
+		page.NextCursor = cleanPath(records[limit-1].Path())
+	}
+
+	for _, record := range records {
+		if record.IsDirectory() {
+			page.Directories = append(page.Directories, cleanPath(record.Path()))
+		} else {
+			page.Files = append(page.Files, cleanPath(record.Path()))
+		}
+	}
+
+	return page, nil
+}
+
+// sqlFileInfoColumns are the columns needed to build a FileInfo,
+// leaving out the (possibly large) contents
+var sqlFileInfoColumns = []string{
+	sqlfilestore.COLUMN_ID,
+	sqlfilestore.COLUMN_TYPE,
+	sqlfilestore.COLUMN_PATH,
+	sqlfilestore.COLUMN_SIZE,
+	sqlfilestore.COLUMN_UPDATED_AT,
+}
+
+// Stat returns the metadata of a file or a directory
+func (s *SQLStorage) Stat(filePath string) (FileInfo, error) {
+	return s.StatContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) StatContext(ctx context.Context, filePath string) (FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return FileInfo{}, err
+	}
+
+	record, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: sqlFileInfoColumns})
+
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	if record == nil {
+		return FileInfo{}, newPathError("stat", filePath, ErrNotFound)
+	}
+
+	return s.fileInfo(record), nil
+}
+
+// List lists the files and sub-directories of the directory with
+// their metadata, in a single query
+func (s *SQLStorage) List(directoryPath string) ([]FileInfo, error) {
+	return s.ListContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) ListContext(ctx context.Context, directoryPath string) ([]FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return []FileInfo{}, err
+	}
+
+	dir, err := s.recordFindByPath(ctx, s.fixPath(directoryPath), sqlfilestore.RecordQueryOptions{Columns: []string{"id", "type"}})
+
+	if err != nil {
+		return []FileInfo{}, err
+	}
+
+	if dir == nil {
+		return []FileInfo{}, newPathError("list", directoryPath, ErrNotFound)
+	}
+
+	if !dir.IsDirectory() {
+		return []FileInfo{}, newPathError("list", directoryPath, ErrNotDirectory)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return []FileInfo{}, err
+	}
+
+	records, err := s.recordList(ctx, sqlfilestore.RecordQueryOptions{
+		ParentID: dir.ID(),
+		Columns:  sqlFileInfoColumns,
+	})
+
+	if err != nil {
+		return []FileInfo{}, err
+	}
+
+	infos := make([]FileInfo, len(records))
+
+	for i := range records {
+		infos[i] = s.fileInfo(&records[i])
+	}
+
+	sortFileInfos(infos)
+
+	return infos, nil
+}
+
+func (s *SQLStorage) fileInfo(record *sqlfilestore.Record) FileInfo {
+	size, _ := strconv.ParseInt(record.Size(), 10, 64)
+	modTime := carbon.Parse(record.UpdatedAt(), carbon.UTC).StdTime()
+
+	// the table keeps no visibility, so all the files have the one of the storage
+	return newFileInfo(s.Visibility, cleanPath(record.Path()), record.IsDirectory(), size, modTime)
+}
+
+// Walk calls fn for every file and directory under root. All the
+// entries are fetched with a single path prefix query.
+func (s *SQLStorage) Walk(root string, fn WalkFunc) error {
+	return s.WalkContext(context.Background(), root, fn)
+}
+
+func (s *SQLStorage) WalkContext(ctx context.Context, root string, fn WalkFunc) error {
+	entries, err := s.walkEntries(ctx, root)
+
+	if err != nil {
+		return walkRootError(root, err, fn)
+	}
+
+	return walkEntries(entries, fn)
+}
+
+// AllFiles lists all the files in the directory and its sub-directories
+func (s *SQLStorage) AllFiles(directoryPath string) ([]string, error) {
+	return s.AllFilesContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) AllFilesContext(ctx context.Context, directoryPath string) ([]string, error) {
+	return walkAll(func(root string, fn WalkFunc) error {
+		return s.WalkContext(ctx, root, fn)
+	}, directoryPath, false)
+}
+
+// AllDirectories lists all the sub-directories of the directory, recursively
+func (s *SQLStorage) AllDirectories(directoryPath string) ([]string, error) {
+	return s.AllDirectoriesContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) AllDirectoriesContext(ctx context.Context, directoryPath string) ([]string, error) {
+	return walkAll(func(root string, fn WalkFunc) error {
+		return s.WalkContext(ctx, root, fn)
+	}, directoryPath, true)
+}
+
+func (s *SQLStorage) walkEntries(ctx context.Context, root string) ([]pageEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rootPath := s.fixPath(root)
+
+	dir, err := s.recordFindByPath(ctx, rootPath, sqlfilestore.RecordQueryOptions{Columns: []string{"id", "type"}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == nil {
+		return nil, newPathError("walk", root, ErrNotFound)
+	}
+
+	if !dir.IsDirectory() {
+		return nil, newPathError("walk", root, ErrNotDirectory)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(rootPath, "/") + "/"
+
+	records, err := s.recordList(ctx, sqlfilestore.RecordQueryOptions{
+		PathStartsWith: prefix,
+		Columns:        []string{sqlfilestore.COLUMN_ID, sqlfilestore.COLUMN_TYPE, sqlfilestore.COLUMN_PATH},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []pageEntry{}
+
+	for _, record := range records {
+		// LIKE treats "_" and "%" in the prefix as wildcards, so check again
+		if record.Path() == rootPath || !strings.HasPrefix(record.Path(), prefix) {
+			continue
+		}
+
+		entries = append(entries, pageEntry{path: cleanPath(record.Path()), isDir: record.IsDirectory()})
+	}
+
+	return entries, nil
+}
+
 func (s *SQLStorage) Exists(path string) (bool, error) {
+	return s.ExistsContext(context.Background(), path)
+}
+
+func (s *SQLStorage) ExistsContext(ctx context.Context, path string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	fixedPath := s.fixPath(path)
 
-	count, err := s.store.RecordCount(sqlfilestore.RecordQueryOptions{
+	count, err := s.recordCount(ctx, sqlfilestore.RecordQueryOptions{
 		Path:    fixedPath,
 		Columns: []string{"id"},
 	})
@@ -324,14 +803,31 @@ func (s *SQLStorage) Exists(path string) (bool, error) {
 }
 
 func (s *SQLStorage) Move(originFilePath, targetFilePath string) error {
-	if originFilePath == targetFilePath {
-		return errors.New("origin and target paths are the same")
+	return s.MoveContext(context.Background(), originFilePath, targetFilePath)
+}
+
+func (s *SQLStorage) MoveContext(ctx context.Context, originFilePath, targetFilePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	record, err := s.store.RecordFindByPath(originFilePath, sqlfilestore.RecordQueryOptions{
+	return s.transaction(ctx, nil, func(tx *SQLStorage) error {
+		return tx.move(ctx, originFilePath, targetFilePath)
+	})
+}
+
+// move moves the record, and recalculates the paths of its children
+func (s *SQLStorage) move(ctx context.Context, originFilePath, targetFilePath string) error {
+
+	if s.fixPath(originFilePath) == s.fixPath(targetFilePath) {
+		return newPathError("move", originFilePath, errors.New("origin and target paths are the same"))
+	}
+
+	record, err := s.recordFindByPath(ctx, s.fixPath(originFilePath), sqlfilestore.RecordQueryOptions{
 		Columns: []string{
 			sqlfilestore.COLUMN_ID,
 			sqlfilestore.COLUMN_PARENT_ID,
+			sqlfilestore.COLUMN_TYPE,
 		},
 	})
 
@@ -340,17 +836,31 @@ func (s *SQLStorage) Move(originFilePath, targetFilePath string) error {
 	}
 
 	if record == nil {
-		return errors.New("origin file or folder path not found")
+		return newPathError("move", originFilePath, ErrNotFound)
 	}
 
-	targetDirectory, err := s.findParentDirectoryFromPath(targetFilePath)
+	if record.IsDirectory() && strings.HasPrefix(s.fixPath(targetFilePath), s.fixPath(originFilePath)+PATH_SEPARATOR) {
+		return newPathError("move", targetFilePath, errors.New("can not move a directory inside itself"))
+	}
+
+	targetDirectory, err := s.findParentDirectoryFromPath(ctx, targetFilePath)
 
 	if err != nil {
 		return err
 	}
 
 	if targetDirectory == nil {
-		return errors.New("target directory not found")
+		return newPathError("move", targetFilePath, ErrNotFound)
+	}
+
+	targetExists, err := s.ExistsContext(ctx, targetFilePath)
+
+	if err != nil {
+		return err
+	}
+
+	if targetExists {
+		return newPathError("move", targetFilePath, ErrAlreadyExists)
 	}
 
 	newName := s.findFileName(targetFilePath)
@@ -359,34 +869,46 @@ func (s *SQLStorage) Move(originFilePath, targetFilePath string) error {
 	record.SetName(newName)
 	record.SetPath(targetDirectory.Path() + PATH_SEPARATOR + newName)
 
-	err = s.store.RecordUpdate(record)
+	err = s.recordUpdate(ctx, record)
 
 	if err != nil {
 		return err
 	}
 
-	return s.store.RecordRecalculatePath(record, targetDirectory)
+	return s.recordRecalculatePath(ctx, record, targetDirectory)
 }
 
 func (s *SQLStorage) MakeDirectory(directoryPath string) error {
-	exists, err := s.Exists(directoryPath)
+	return s.MakeDirectoryContext(context.Background(), directoryPath)
+}
+
+func (s *SQLStorage) MakeDirectoryContext(ctx context.Context, directoryPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.MakeDirectoryParents {
+		return s.MakeDirectoryAllContext(ctx, directoryPath)
+	}
+
+	exists, err := s.ExistsContext(ctx, directoryPath)
 
 	if err != nil {
 		return err
 	}
 
 	if exists {
-		return errors.New("directory already exists")
+		return newPathError("mkdir", directoryPath, ErrAlreadyExists)
 	}
 
-	parentDir, err := s.findParentDirectoryFromPath(directoryPath)
+	parentDir, err := s.findParentDirectoryFromPath(ctx, directoryPath)
 
 	if err != nil {
 		return err
 	}
 
 	if parentDir == nil {
-		return errors.New("parent directory not found")
+		return newPathError("mkdir", directoryPath, ErrNotFound)
 	}
 
 	directoryName := s.findFileName(directoryPath)
@@ -396,7 +918,7 @@ func (s *SQLStorage) MakeDirectory(directoryPath string) error {
 		SetName(directoryName).
 		SetPath(parentDir.Path() + PATH_SEPARATOR + directoryName)
 
-	err = s.store.RecordCreate(directory)
+	err = s.recordCreate(ctx, directory)
 
 	if err != nil {
 		return err
@@ -409,52 +931,305 @@ func (s *SQLStorage) MakeDirectory(directoryPath string) error {
 // 	return false
 // }
 
+// Put writes the file. An existing file is updated in place.
 func (s *SQLStorage) Put(filePath string, content []byte) error {
-	parentDir, err := s.findParentDirectoryFromPath(filePath)
+	return s.PutContext(context.Background(), filePath, content)
+}
+
+func (s *SQLStorage) PutContext(ctx context.Context, filePath string, content []byte) error {
+	return s.put(ctx, filePath, bytes.NewReader(content), PutOptions{})
+}
+
+// PutWithOptions writes the file, with IfNotExists only creating it
+func (s *SQLStorage) PutWithOptions(filePath string, content []byte, options PutOptions) error {
+	return s.PutWithOptionsContext(context.Background(), filePath, content, options)
+}
+
+func (s *SQLStorage) PutWithOptionsContext(ctx context.Context, filePath string, content []byte, options PutOptions) error {
+	return s.put(ctx, filePath, bytes.NewReader(content), options)
+}
+
+// put writes the contents of the reader to the file. The file, and its
+// missing directories, are written in a serializable transaction, and
+// the writers of this storage take turns, so concurrent writers do not
+// create the same file or directory twice. A failed transaction is
+// retried, as the file is found on the next attempt, so a reader which
+// can not seek is spooled first, to be read again.
+// Within WithTx the file is written in its transaction instead.
+func (s *SQLStorage) put(ctx context.Context, filePath string, reader io.Reader, options PutOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.tx != nil {
+		return s.putFile(ctx, filePath, reader, options)
+	}
+
+	seeker, ok := reader.(io.ReadSeeker)
+
+	if !ok {
+		spooled, err := s.spool(reader)
+
+		if err != nil {
+			return newPathError("put", filePath, err)
+		}
+
+		defer spooled.Close()
+
+		seeker = spooled
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+		return newPathError("put", filePath, err)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for attempt := 0; attempt < sqlWriteAttempts; attempt++ {
+		if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return newPathError("put", filePath, err)
+		}
+
+		err = s.transaction(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *SQLStorage) error {
+			return tx.putFile(ctx, filePath, seeker, options)
+		})
+
+		if err == nil {
+			return nil
+		}
+
+		var pathErr *PathError
+
+		// a path error (i.e. IfNotExists on an existing file) fails the same way again
+		if errors.As(err, &pathErr) || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// spool reads the reader to the end, so it can be read again. The
+// chunked contents are spooled to a temporary file, removed when closed,
+// as they may be large, and the others to memory, where they are read
+// into anyway.
+func (s *SQLStorage) spool(reader io.Reader) (io.ReadSeekCloser, error) {
+	if !s.ChunkedContents {
+		content, err := io.ReadAll(reader)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return bytesReadSeekCloser{bytes.NewReader(content)}, nil
+	}
+
+	file, err := os.CreateTemp("", "filesystem-*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	spooled := &tempFile{File: file}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+
+	return spooled, nil
+}
+
+// putFile writes the contents of the reader to the file, creating the
+// missing directories, and the record, or updating the existing one in
+// place, so there is never more than one record for a path
+func (s *SQLStorage) putFile(ctx context.Context, filePath string, reader io.Reader, options PutOptions) error {
+	parentDir, err := s.findPutDirectory(ctx, filePath)
 
 	if err != nil {
 		return err
 	}
 
-	if parentDir == nil {
-		return errors.New("parent directory not found")
+	existing, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{
+		Columns: []string{sqlfilestore.COLUMN_ID, sqlfilestore.COLUMN_TYPE},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if existing != nil && options.IfNotExists {
+		return newPathError("put", filePath, ErrAlreadyExists)
+	}
+
+	if existing != nil && !existing.IsFile() {
+		return newPathError("put", filePath, ErrNotFile)
 	}
 
-	b64 := base64.StdEncoding.EncodeToString(content)
 	fileName := s.findFileName(filePath)
-	fileExtension := s.findExtension(filePath)
-	filePath = parentDir.Path() + PATH_SEPARATOR + fileName
+	file := existing
 
-	file := sqlfilestore.NewFile().
-		SetParentID(parentDir.ID()).
-		SetName(fileName).
-		SetContents(b64).
-		SetExtension(fileExtension).
-		SetPath(filePath).
-		SetSize(utils.ToString(len(content)))
+	if file == nil {
+		file = sqlfilestore.NewFile().
+			SetParentID(parentDir.ID()).
+			SetName(fileName).
+			SetPath(parentDir.Path() + PATH_SEPARATOR + fileName)
+	}
+
+	file.SetExtension(s.findExtension(filePath))
 
-	err = s.store.RecordCreate(file)
+	if s.ChunkedContents {
+		err = s.putChunks(ctx, file, existing != nil, reader)
+	} else {
+		err = s.putContents(file, reader)
+	}
 
 	if err != nil {
 		return err
 	}
 
+	if existing != nil {
+		return s.recordUpdate(ctx, file)
+	}
+
+	return s.recordCreate(ctx, file)
+}
+
+// putContents sets the contents of the file record, base64 encoded
+func (s *SQLStorage) putContents(file *sqlfilestore.Record, reader io.Reader) error {
+	content, err := io.ReadAll(reader)
+
+	if err != nil {
+		return newPathError("put", file.Path(), err)
+	}
+
+	file.SetContents(base64.StdEncoding.EncodeToString(content))
+	file.SetSize(utils.ToString(len(content)))
+
 	return nil
 }
 
+// findPutDirectory finds the directory a file is put in,
+// creating it when missing, unless RequireParentDirectories
+func (s *SQLStorage) findPutDirectory(ctx context.Context, filePath string) (*sqlfilestore.Record, error) {
+	parentDir, err := s.findParentDirectoryFromPath(ctx, filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parentDir == nil && s.RequireParentDirectories {
+		return nil, newPathError("put", filePath, ErrNotFound)
+	}
+
+	if parentDir == nil {
+		return s.makeDirectoryAll(ctx, "put", path.Dir(s.fixPath(filePath)))
+	}
+
+	if !parentDir.IsDirectory() {
+		return nil, newPathError("put", filePath, ErrNotDirectory)
+	}
+
+	return parentDir, nil
+}
+
+// PutStream writes the contents of the reader to the file. With chunked
+// contents, the reader is stored a chunk at a time. Otherwise the contents
+// are stored base64 encoded in a single column, so they are read into
+// memory before being written.
+func (s *SQLStorage) PutStream(filePath string, reader io.Reader, size int64) error {
+	return s.PutStreamContext(context.Background(), filePath, reader, size)
+}
+
+func (s *SQLStorage) PutStreamContext(ctx context.Context, filePath string, reader io.Reader, size int64) error {
+	return s.put(ctx, filePath, reader, PutOptions{})
+}
+
+// OpenReader opens the file for reading. The chunks, or the contents
+// column, are read as the reader is read, instead of all up front.
+func (s *SQLStorage) OpenReader(filePath string) (io.ReadCloser, error) {
+	return s.OpenReaderContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) OpenReaderContext(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	file, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: []string{"id", "type", "contents"}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if file == nil {
+		return nil, newPathError("read", filePath, ErrNotFound)
+	}
+
+	if !file.IsFile() {
+		return nil, newPathError("read", filePath, ErrNotFile)
+	}
+
+	if s.ChunkedContents && file.Contents() == "" {
+		return &sqlChunkReader{ctx: ctx, storage: s, fileID: file.ID()}, nil
+	}
+
+	return io.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(file.Contents()))), nil
+}
+
+// OpenWriter opens the file for writing. The written data is collected
+// in memory (in a temporary file, with chunked contents), and stored
+// when the writer is closed.
+func (s *SQLStorage) OpenWriter(filePath string) (io.WriteCloser, error) {
+	return s.OpenWriterContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) OpenWriterContext(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	if s.ChunkedContents {
+		return newTempFileWriter(func(file *os.File, size int64) error {
+			return s.put(ctx, filePath, file, PutOptions{})
+		})
+	}
+
+	return &bufferWriter{flush: func(content []byte) error {
+		return s.PutContext(ctx, filePath, content)
+	}}, nil
+}
+
 func (s *SQLStorage) PutFile(filePath string, source string) (string, error) {
-	return "", errors.New("not implemented")
+	return "", newPathError("putfile", filePath, ErrNotSupported)
 }
 
 func (s *SQLStorage) ReadFile(filePath string) ([]byte, error) {
-	file, err := s.store.RecordFindByPath(filePath, sqlfilestore.RecordQueryOptions{Columns: []string{"contents"}})
+	return s.ReadFileContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) ReadFileContext(ctx context.Context, filePath string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: []string{"id", "type", "contents"}})
 
 	if err != nil {
 		return nil, err
 	}
 
 	if file == nil {
-		return nil, errors.New("file not found")
+		return nil, newPathError("read", filePath, ErrNotFound)
+	}
+
+	if !file.IsFile() {
+		return nil, newPathError("read", filePath, ErrNotFile)
+	}
+
+	if s.ChunkedContents && file.Contents() == "" {
+		content, _, err := s.readChunks(ctx, file.ID(), 0, maxChunkOffset)
+		return content, err
 	}
 
 	b, err := base64.StdEncoding.DecodeString(file.Contents())
@@ -466,13 +1241,183 @@ func (s *SQLStorage) ReadFile(filePath string) ([]byte, error) {
 	return b, nil
 }
 
+// ReadRange reads length bytes of the file, starting at offset. Only the
+// part of the base64 encoded contents column covering the range is
+// selected and decoded.
+func (s *SQLStorage) ReadRange(filePath string, offset, length int64) ([]byte, error) {
+	return s.ReadRangeContext(context.Background(), filePath, offset, length)
+}
+
+func (s *SQLStorage) ReadRangeContext(ctx context.Context, filePath string, offset, length int64) ([]byte, error) {
+	fileID, size, err := s.rangeFile(ctx, filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.readRange(ctx, filePath, fileID, size, offset, length)
+}
+
+// Open opens the file for random access reading. Reads select
+// a block of whole chunks, or of whole base64 groups, at a time.
+func (s *SQLStorage) Open(filePath string) (io.ReadSeekCloser, error) {
+	return s.OpenContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) OpenContext(ctx context.Context, filePath string) (io.ReadSeekCloser, error) {
+	fileID, size, err := s.rangeFile(ctx, filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rangeReader{
+		size:      size,
+		blockSize: s.readBlockSize(),
+		readRange: func(offset, length int64) ([]byte, error) {
+			return s.readRange(ctx, filePath, fileID, size, offset, length)
+		},
+	}, nil
+}
+
+// sqlReadBlockSize is the size of the blocks read by Open, in whole base64 groups
+const sqlReadBlockSize = 3 * 64 * 1024
+
+// readBlockSize returns the size of the blocks read by Open, rounded
+// up to whole chunks with chunked contents
+func (s *SQLStorage) readBlockSize() int64 {
+	if !s.ChunkedContents {
+		return sqlReadBlockSize
+	}
+
+	chunkSize := int64(s.ChunkSize)
+
+	return (sqlReadBlockSize + chunkSize - 1) / chunkSize * chunkSize
+}
+
+// rangeFile finds the size of the file, and its ID with chunked contents
+func (s *SQLStorage) rangeFile(ctx context.Context, filePath string) (string, int64, error) {
+	size, err := s.SizeContext(ctx, filePath)
+
+	if err != nil || !s.ChunkedContents {
+		return "", size, err
+	}
+
+	file, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: []string{"id"}})
+
+	if err != nil {
+		return "", -1, err
+	}
+
+	if file == nil {
+		return "", -1, newPathError("read", filePath, ErrNotFound)
+	}
+
+	return file.ID(), size, nil
+}
+
+// readRange reads a range of a file of known size, from the chunks
+// overlapping the range, when the file has any. Otherwise from the
+// contents column: every 3 bytes of content are encoded as 4 base64
+// characters, so the range is widened to whole groups, selected with
+// SUBSTR, and trimmed after decoding.
+func (s *SQLStorage) readRange(ctx context.Context, filePath, fileID string, size, offset, length int64) ([]byte, error) {
+	start, end, err := rangeBounds(offset, length, size)
+
+	if err != nil {
+		return nil, newPathError("read", filePath, err)
+	}
+
+	if start == end {
+		return []byte{}, nil
+	}
+
+	if fileID != "" {
+		content, found, err := s.readChunks(ctx, fileID, start, end)
+
+		if err != nil || found {
+			return content, err
+		}
+	}
+
+	firstGroup := start / 3
+	lastGroup := (end + 2) / 3
+
+	sqlStr, params, err := goqu.Dialect(s.dbDriverName).
+		From(s.FilestoreTable).
+		Prepared(true).
+		Select(goqu.Func("SUBSTR", goqu.C(sqlfilestore.COLUMN_CONTENTS), firstGroup*4+1, (lastGroup-firstGroup)*4).As("chunk")).
+		Where(
+			goqu.C(sqlfilestore.COLUMN_PATH).Eq(s.fixPath(filePath)),
+			goqu.C(sqlfilestore.COLUMN_DELETED_AT).Eq(sb.NULL_DATETIME),
+		).
+		Limit(1).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.DebugEnabled {
+		log.Println(sqlStr)
+	}
+
+	var chunk string
+
+	err = s.executor().QueryRowContext(ctx, sqlStr, params...).Scan(&chunk)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newPathError("read", filePath, ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(chunk)
+
+	if err != nil {
+		return nil, err
+	}
+
+	skip := start - firstGroup*3
+
+	if skip > int64(len(decoded)) {
+		return []byte{}, nil
+	}
+
+	decoded = decoded[skip:]
+
+	if int64(len(decoded)) > end-start {
+		decoded = decoded[:end-start]
+	}
+
+	return decoded, nil
+}
+
 func (s *SQLStorage) Size(filePath string) (int64, error) {
-	file, err := s.store.RecordFindByPath(filePath, sqlfilestore.RecordQueryOptions{Columns: []string{"size"}})
+	return s.SizeContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) SizeContext(ctx context.Context, filePath string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	file, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: []string{"type", "size"}})
 
 	if err != nil {
 		return -1, err
 	}
 
+	if file == nil {
+		return -1, newPathError("size", filePath, ErrNotFound)
+	}
+
+	if !file.IsFile() {
+		return -1, newPathError("size", filePath, ErrNotFile)
+	}
+
 	sizeString := file.Size()
 
 	if sizeString == "" {
@@ -489,39 +1434,61 @@ func (s *SQLStorage) Size(filePath string) (int64, error) {
 }
 
 func (s *SQLStorage) LastModified(filePath string) (time.Time, error) {
-	file, err := s.store.RecordFindByPath(filePath, sqlfilestore.RecordQueryOptions{Columns: []string{"updated_at"}})
+	return s.LastModifiedContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) LastModifiedContext(ctx context.Context, filePath string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	file, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: []string{"updated_at"}})
 
 	if err != nil {
 		return carbon.Parse(sb.NULL_DATETIME).StdTime(), err
 	}
 
+	if file == nil {
+		return carbon.Parse(sb.NULL_DATETIME).StdTime(), newPathError("lastmodified", filePath, ErrNotFound)
+	}
+
 	strUpdatedAt := file.UpdatedAt()
 
 	return carbon.Parse(strUpdatedAt, carbon.UTC).StdTime(), nil
 }
 
 func (s *SQLStorage) Url(filePath string) (string, error) {
-	file, err := s.store.RecordFindByPath(filePath, sqlfilestore.RecordQueryOptions{Columns: []string{"path"}})
+	return s.UrlContext(context.Background(), filePath)
+}
+
+func (s *SQLStorage) UrlContext(ctx context.Context, filePath string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	file, err := s.recordFindByPath(ctx, s.fixPath(filePath), sqlfilestore.RecordQueryOptions{Columns: []string{"path"}})
 
 	if err != nil {
 		return "", err
 	}
 
-	path := file.Path()
+	if file == nil {
+		return "", newPathError("url", filePath, ErrNotFound)
+	}
+
+	filePath = file.Path()
 
 	if s.URL != "" {
-		path = s.URL + path
+		filePath = joinUrl(s.URL, filePath)
 	}
 
-	return path, nil
+	return filePath, nil
 }
 
-func (s *SQLStorage) fixPath(path string) string {
-	if strings.HasPrefix(path, PATH_SEPARATOR) {
-		return path
-	}
-
-	return PATH_SEPARATOR + path
+// fixPath converts a storage path to the absolute form used by
+// the file store, i.e. "dir/file.txt" becomes "/dir/file.txt"
+func (s *SQLStorage) fixPath(filePath string) string {
+	return ROOT_PATH + cleanPath(filePath)
 }
 
 // findExtension finds the file extension from a path.
@@ -1,11 +1,25 @@
 package filesystem
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 
-	_ "modernc.org/sqlite"
+	"github.com/gouniverse/sb"
+	"modernc.org/sqlite"
 )
 
 func sqlStorageInitDB(filepath string) *sql.DB {
@@ -78,3 +92,843 @@ func TestSqlStorageReadFile(t *testing.T) {
 	}
 
 }
+
+func TestSqlStorageContextCanceled(t *testing.T) {
+	db := sqlStorageInitDB(":memory:")
+
+	s, err := NewSqlStorage(SqlStorageOptions{
+		DB:                 db,
+		FilestoreTable:     "sqlstore",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = s.PutContext(ctx, "test.txt", []byte("test"))
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+
+	exists, err := s.Exists("test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if exists {
+		t.Fatal("expected file not to be created")
+	}
+}
+
+func TestSqlStorageStreamContextCanceled(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		s := sqlStorageChunkedInit(t, sqlStorageTestDB(t), chunked)
+
+		if err := s.Put("test.txt", []byte("test")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := s.PutStreamContext(ctx, "stream.txt", strings.NewReader("test"), 4)
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal("expected context.Canceled, got:", err)
+		}
+
+		_, err = s.OpenReaderContext(ctx, "test.txt")
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal("expected context.Canceled, got:", err)
+		}
+
+		writer, err := s.OpenWriterContext(ctx, "writer.txt")
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if _, err := writer.Write([]byte("test")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := writer.Close(); !errors.Is(err, context.Canceled) {
+			t.Fatal("expected context.Canceled, got:", err)
+		}
+
+		for _, file := range []string{"stream.txt", "writer.txt"} {
+			exists, err := s.Exists(file)
+
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if exists {
+				t.Fatal("expected file not to be created:", file)
+			}
+		}
+	}
+}
+
+func TestSqlStorageOpenBuffersReads(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	for _, chunked := range []bool{false, true} {
+		s := sqlStorageChunkedInit(t, sqlStorageTestDB(t), chunked)
+
+		if err := s.Put("test.txt", content); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		reader, err := s.Open("test.txt")
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		defer reader.Close()
+
+		queries := &bytes.Buffer{}
+		log.SetOutput(queries)
+		defer log.SetOutput(os.Stderr)
+		s.DebugEnabled = true
+
+		data, err := io.ReadAll(iotest.OneByteReader(reader))
+
+		s.DebugEnabled = false
+		log.SetOutput(os.Stderr)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if !bytes.Equal(data, content) {
+			t.Fatal("unexpected content:", string(data))
+		}
+
+		if count := strings.Count(queries.String(), "SELECT"); count != 1 {
+			t.Fatal("expected the file to be read with 1 query, got:", count)
+		}
+	}
+}
+
+func TestSqlStorageRangeContextCanceled(t *testing.T) {
+	s := sqlStorageChunkedInit(t, sqlStorageTestDB(t), false)
+
+	if err := s.Put("test.txt", []byte("test")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	reader, err := s.OpenContext(ctx, "test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	defer reader.Close()
+
+	cancel()
+
+	if _, err := io.ReadAll(reader); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+
+	if _, err := s.ReadRangeContext(ctx, "test.txt", 0, 2); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+}
+
+// NewSqlTestStorage creates a SQL storage in an in-memory database,
+// i.e. for the conformance tests in the filesystem_test package
+func NewSqlTestStorage(t *testing.T) StorageInterface {
+	return sqlStorageChunkedInit(t, sqlStorageTestDB(t), false)
+}
+
+// NewSqlChunkedTestStorage creates a SQL storage with chunked contents,
+// in chunks small enough for most files to span several of them
+func NewSqlChunkedTestStorage(t *testing.T) StorageInterface {
+	return sqlStorageChunkedInit(t, sqlStorageTestDB(t), true)
+}
+
+// sqlStorageTestDB opens an in-memory database, closed with the test
+func sqlStorageTestDB(t *testing.T) *sql.DB {
+	db := sqlStorageInitDB(":memory:")
+	db.SetMaxOpenConns(1) // every connection to :memory: opens a new database
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func sqlStorageChunkedInit(t *testing.T, db *sql.DB, chunked bool) *SQLStorage {
+	s, err := NewSqlStorage(SqlStorageOptions{
+		DB:                 db,
+		FilestoreTable:     "sqlstore",
+		AutomigrateEnabled: true,
+		ChunkedContents:    chunked,
+		ChunkSize:          4,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return s
+}
+
+func TestSqlStorageChunked(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	if err := s.PutStream("test.txt", strings.NewReader("hello chunked world"), -1); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var chunks int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore_chunk").Scan(&chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if chunks != 5 {
+		t.Fatal("unexpected chunk count:", chunks)
+	}
+
+	var contents string
+
+	if err := db.QueryRow("SELECT contents FROM sqlstore WHERE path = '/test.txt'").Scan(&contents); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if contents != "" {
+		t.Fatal("expected the contents column to be empty, got:", contents)
+	}
+
+	if err := s.Copy("test.txt", "copy.txt"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore_chunk").Scan(&chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if chunks != 10 {
+		t.Fatal("expected the copy to have its own chunks, got:", chunks)
+	}
+}
+
+func TestSqlStorageChunkedDeleteRemovesChunks(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	for _, filePath := range []string{"deleted.txt", "dir/deleted.txt", "replaced.txt", "kept.txt"} {
+		if err := s.Put(filePath, []byte("12345678")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	if err := s.DeleteFile([]string{"deleted.txt"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.DeleteDirectory("dir"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.Copy("kept.txt", "replaced.txt"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var chunks int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore_chunk").Scan(&chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// two chunks each for kept.txt and its copy
+	if chunks != 4 {
+		t.Fatal("unexpected chunk count:", chunks)
+	}
+}
+
+func TestSqlStorageMigrateContentsToChunks(t *testing.T) {
+	db := sqlStorageTestDB(t)
+
+	legacy := sqlStorageChunkedInit(t, db, false)
+
+	if err := legacy.Put("old.txt", []byte("stored as base64")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := legacy.Put("deleted.txt", []byte("deleted")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := legacy.DeleteFile([]string{"deleted.txt"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := legacy.MigrateContentsToChunks(); err == nil {
+		t.Fatal("expected an error without chunked contents")
+	}
+
+	s := sqlStorageChunkedInit(t, db, true)
+
+	// not migrated yet, so read from the contents column
+	data, err := s.ReadRange("old.txt", 10, 6)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "base64" {
+		t.Fatal("unexpected range:", string(data))
+	}
+
+	if err := s.MigrateContentsToChunks(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var contents string
+
+	if err := db.QueryRow("SELECT contents FROM sqlstore WHERE path = '/old.txt'").Scan(&contents); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if contents != "" {
+		t.Fatal("expected the contents column to be empty, got:", contents)
+	}
+
+	var chunks int
+
+	// 16 bytes of old.txt in chunks of 4, and none for the deleted file
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore_chunk").Scan(&chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if chunks != 4 {
+		t.Fatal("unexpected chunk count:", chunks)
+	}
+
+	data, err = s.ReadFile("old.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "stored as base64" {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	// running again is a no-op
+	if err := s.MigrateContentsToChunks(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestSqlStoragePutOverwrites(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, false)
+
+	if err := s.Put("test.txt", []byte("first version")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.Put("test.txt", []byte("second")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore WHERE path = '/test.txt'").Scan(&count); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("expected a single record, got:", count)
+	}
+
+	size, err := s.Size("test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if size != 6 {
+		t.Fatal("unexpected size:", size)
+	}
+
+	if err := s.MakeDirectory("dir"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.Put("dir", []byte("not a directory")); !errors.Is(err, ErrNotFile) {
+		t.Fatal("expected ErrNotFile, got:", err)
+	}
+}
+
+func TestSqlStoragePutCreatesParents(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, false)
+
+	if err := s.Put("a/b/c/test.txt", []byte("nested")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	directories, err := s.AllDirectories("")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if strings.Join(directories, ",") != "a,a/b,a/b/c" {
+		t.Fatal("unexpected directories:", directories)
+	}
+
+	if err := s.Put("a/b/c/test.txt/nested.txt", []byte("nested")); !errors.Is(err, ErrNotDirectory) {
+		t.Fatal("expected ErrNotDirectory, got:", err)
+	}
+
+	if err := s.MakeDirectory("x/y"); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got:", err)
+	}
+
+	if err := s.MakeDirectoryAll("x/y"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.MakeDirectoryAll("x/y"); err != nil {
+		t.Fatal("expected no error for an existing directory, got:", err)
+	}
+
+	strict, err := NewSqlStorage(SqlStorageOptions{
+		DB:                       db,
+		FilestoreTable:           "sqlstore",
+		RequireParentDirectories: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := strict.Put("missing/test.txt", []byte("test")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got:", err)
+	}
+}
+
+func TestSqlStorageConcurrentPutCreatesParentsOnce(t *testing.T) {
+	// SQLite allows a single writer, so the transactions take the
+	// write lock up front, and the other writers wait for it
+	dbPath := filepath.Join(t.TempDir(), "concurrent.db")
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	// two storages, as two processes, sharing the database
+	first := sqlStorageChunkedInit(t, db, false)
+	second := sqlStorageChunkedInit(t, db, false)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 20)
+
+	for i := 0; i < 10; i++ {
+		for _, s := range []*SQLStorage{first, second} {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				errs <- s.Put("shared/dir/file"+strconv.Itoa(i)+".txt", []byte("test"))
+			}()
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore WHERE type = 'directory'").Scan(&count); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the root, shared and shared/dir
+	if count != 3 {
+		t.Fatal("expected 3 directories, got:", count)
+	}
+}
+
+func TestSqlStorageConcurrentPutCreatesFileOnce(t *testing.T) {
+	// SQLite allows a single writer, so this guards the behaviour, but
+	// can not reproduce the race of databases with concurrent writers
+	dbPath := filepath.Join(t.TempDir(), "concurrent.db")
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	first := sqlStorageChunkedInit(t, db, false)
+	second := sqlStorageChunkedInit(t, db, false)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 20)
+
+	for i := 0; i < 10; i++ {
+		for _, s := range []*SQLStorage{first, second} {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				errs <- s.Put("same.txt", []byte("version "+strconv.Itoa(i)))
+			}()
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore WHERE path = '/same.txt'").Scan(&count); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("expected 1 record, got:", count)
+	}
+}
+
+// sqlStorageConflicts is the number of inserts failing next, as on a
+// conflict, in the databases with the sqlstore_conflict trigger
+var (
+	sqlStorageConflicts        atomic.Int32
+	sqlStorageConflictRegister sync.Once
+)
+
+func TestSqlStoragePutRetriesConflicts(t *testing.T) {
+	sqlStorageConflictRegister.Do(func() {
+		sqlite.MustRegisterScalarFunction("sqlstore_conflict", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+			if sqlStorageConflicts.Add(-1) >= 0 {
+				return nil, errors.New("could not serialize access")
+			}
+
+			return nil, nil
+		})
+	})
+
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	if _, err := db.Exec("CREATE TRIGGER sqlstore_conflict BEFORE INSERT ON sqlstore WHEN NEW.type = 'file' BEGIN SELECT sqlstore_conflict(); END"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	sqlStorageConflicts.Store(sqlWriteAttempts - 1)
+	t.Cleanup(func() { sqlStorageConflicts.Store(0) })
+
+	// a reader, which can not seek, is read again on every attempt
+	reader := iotest.OneByteReader(strings.NewReader("written on the last attempt"))
+
+	if err := s.PutStream("dir/test.txt", reader, -1); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err := s.ReadFile("dir/test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "written on the last attempt" {
+		t.Fatal("unexpected content:", string(data))
+	}
+
+	sqlStorageConflicts.Store(sqlWriteAttempts)
+
+	if err := s.Put("failed.txt", []byte("test")); err == nil {
+		t.Fatal("expected an error after the last attempt")
+	}
+}
+
+func TestSqlStorageWithTxRollsBack(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	if _, err := db.Exec("CREATE TABLE attachment (path TEXT)"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	failure := errors.New("domain failure")
+
+	err := s.WithTx(func(tx StorageInterface) error {
+		if err := tx.Put("dir/test.txt", []byte("test")); err != nil {
+			return err
+		}
+
+		if _, err := tx.(*SQLStorage).Tx().Exec("INSERT INTO attachment (path) VALUES ('dir/test.txt')"); err != nil {
+			return err
+		}
+
+		exists, err := tx.Exists("dir/test.txt")
+
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			t.Error("expected the file to exist within the transaction")
+		}
+
+		return failure
+	})
+
+	if !errors.Is(err, failure) {
+		t.Fatal("expected the domain failure, got:", err)
+	}
+
+	for _, filePath := range []string{"dir", "dir/test.txt"} {
+		exists, err := s.Exists(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if exists {
+			t.Fatal("expected the put to be rolled back:", filePath)
+		}
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM attachment").Scan(&count); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("expected the domain row to be rolled back, got:", count)
+	}
+}
+
+func TestSqlStorageWithTxCommits(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, false)
+
+	if err := s.Put("dir/a.txt", []byte("a")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err := s.WithTx(func(tx StorageInterface) error {
+		if err := tx.Move("dir", "moved"); err != nil {
+			return err
+		}
+
+		if err := tx.Copy("moved/a.txt", "moved/b.txt"); err != nil {
+			return err
+		}
+
+		// nested calls join the outer transaction
+		return tx.(*SQLStorage).WithTx(func(tx StorageInterface) error {
+			return tx.DeleteFile([]string{"moved/a.txt"})
+		})
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	files, err := s.Files("moved")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if strings.Join(files, ",") != "moved/b.txt" {
+		t.Fatal("unexpected files:", files)
+	}
+}
+
+func TestSqlStorageFailedPutKeepsContents(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	if err := s.Put("test.txt", []byte("first version")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	failure := errors.New("read failure")
+	reader := io.MultiReader(strings.NewReader("second version"), iotest.ErrReader(failure))
+
+	if err := s.PutStream("test.txt", reader, -1); !errors.Is(err, failure) {
+		t.Fatal("expected the read failure, got:", err)
+	}
+
+	data, err := s.ReadFile("test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "first version" {
+		t.Fatal("expected the previous contents, got:", string(data))
+	}
+}
+
+func TestSqlStorageFailedPutCreatesNoDirectories(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	failure := errors.New("read failure")
+
+	if err := s.PutStream("new/dir/test.txt", iotest.ErrReader(failure), -1); !errors.Is(err, failure) {
+		t.Fatal("expected the read failure, got:", err)
+	}
+
+	exists, err := s.Exists("new")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if exists {
+		t.Fatal("expected the directories to be rolled back with the file")
+	}
+}
+
+func TestSqlStorageCopyDirectoryOverwrites(t *testing.T) {
+	db := sqlStorageTestDB(t)
+	s := sqlStorageChunkedInit(t, db, true)
+
+	if err := s.Put("dir/sub/a.txt", []byte("first")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.CopyDirectory("dir", "copy"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.Put("dir/sub/a.txt", []byte("second")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := s.CopyDirectoryWithOptions("dir", "copy", CopyDirectoryOptions{Overwrite: true}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlstore WHERE path = '/copy/sub/a.txt' AND deleted_at = ?", sb.NULL_DATETIME).Scan(&count); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("expected a single record, got:", count)
+	}
+
+	data, err := s.ReadFile("copy/sub/a.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "second" {
+		t.Fatal("unexpected content:", string(data))
+	}
+}
+
+func TestSqlValueString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"nil", nil, ""},
+		{"string", "text", "text"},
+		{"bytes", []byte("text"), "text"},
+		{"int64", int64(1024), "1024"},
+		{"float64", float64(1024), "1024"},
+		{"float64 fraction", 0.125, "0.125"},
+		{"bool", true, "true"},
+		{"time", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), "2024-05-06 07:08:09"},
+		{"time not UTC", time.Date(2024, 5, 6, 8, 8, 9, 0, time.FixedZone("CET", 3600)), "2024-05-06 07:08:09"},
+		{"null datetime", time.Date(2, 1, 1, 0, 0, 0, 0, time.UTC), sb.NULL_DATETIME},
+	}
+
+	for _, test := range tests {
+		if got := sqlValueString(test.value); got != test.want {
+			t.Fatal(test.name, "expected", test.want, "got:", got)
+		}
+	}
+}
+
+func TestSqlStorageVisibility(t *testing.T) {
+	for _, visibility := range []string{"", VISIBILITY_PRIVATE} {
+		storage, err := NewStorage(Disk{
+			DiskName:   "sql",
+			Driver:     DRIVER_SQL,
+			DB:         sqlStorageTestDB(t),
+			TableName:  "sqlstore",
+			Url:        "http://localhost/files",
+			Visibility: visibility,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := storage.Put("dir/test.txt", []byte("test")); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		expected := visibility
+
+		if expected == "" {
+			expected = VISIBILITY_PUBLIC
+		}
+
+		info, err := storage.(StorageStatInterface).Stat("dir/test.txt")
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if info.Visibility != expected {
+			t.Fatal("expected visibility", expected, "got:", info.Visibility)
+		}
+
+		infos, err := storage.(StorageStatInterface).List("dir")
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if len(infos) != 1 || infos[0].Visibility != expected {
+			t.Fatal("unexpected infos:", infos)
+		}
+	}
+}
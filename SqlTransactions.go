@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"context"
+	"database/sql"
+)
+
+// WithTx runs fn in a database transaction, with tx running all its
+// statements in it. The transaction is committed when fn returns nil,
+// and rolled back when it returns an error or panics. Nested calls
+// join the outer transaction.
+//
+// The transaction is available to fn as tx.(*SQLStorage).Tx(), so the
+// domain rows related to the files can be written atomically with them.
+func (s *SQLStorage) WithTx(fn func(tx StorageInterface) error) error {
+	return s.WithTxContext(context.Background(), fn)
+}
+
+func (s *SQLStorage) WithTxContext(ctx context.Context, fn func(tx StorageInterface) error) error {
+	return s.transaction(ctx, nil, func(tx *SQLStorage) error {
+		return fn(tx)
+	})
+}
+
+// Tx returns the transaction the storage runs in, or nil outside of one
+func (s *SQLStorage) Tx() *sql.Tx {
+	return s.tx
+}
+
+// UsingTx returns a copy of the storage running its statements in the
+// transaction, which is committed or rolled back by the caller
+func (s *SQLStorage) UsingTx(tx *sql.Tx) *SQLStorage {
+	clone := *s
+	clone.tx = tx
+	return &clone
+}
+
+// transaction runs fn with a copy of the storage bound to a new
+// transaction, or with the storage itself when already in one
+func (s *SQLStorage) transaction(ctx context.Context, options *sql.TxOptions, fn func(tx *SQLStorage) error) (err error) {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, options)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	return fn(s.UsingTx(tx))
+}
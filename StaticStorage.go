@@ -1,68 +1,355 @@
 package filesystem
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 // StaticStorage implements StorageInterface
-// it represents a static (read only) storage, i.e. CDN
-// the only supported method is Url(filepath)
+// it represents a static (read only) storage served over HTTP, i.e. CDN.
+// The files are read with HEAD and GET requests against Disk.Url.
+// Listing the files requires a manifest (see Disk.ManifestPath), which
+// also maps the files to their fingerprinted paths, if any.
 type StaticStorage struct {
 	disk Disk
+
+	// the manifest is loaded on first use, and kept until refreshed
+	manifestMu sync.Mutex
+	manifest   Manifest
 }
 
-var _ StorageInterface = (*StaticStorage)(nil) // verify it extends the task interface
+var _ StorageInterface = (*StaticStorage)(nil)     // verify it extends the task interface
+var _ StorageCopyInterface = (*StaticStorage)(nil) // verify it extends the storage copy interface
+
+// staticDriver creates the storage of a DRIVER_STATIC disk
+func staticDriver(disk Disk) (StorageInterface, error) {
+	return &StaticStorage{disk: disk}, nil
+}
 
 func (s *StaticStorage) Copy(originFile, targetFile string) error {
-	return errors.New("not supported")
+	return newPathError("copy", originFile, ErrReadOnly)
+}
+
+func (s *StaticStorage) CopyDirectory(sourceDir, targetDir string) error {
+	return newPathError("copy", sourceDir, ErrReadOnly)
+}
+
+func (s *StaticStorage) CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error {
+	return newPathError("copy", sourceDir, ErrReadOnly)
 }
 
 func (s *StaticStorage) DeleteFile(filePaths []string) error {
-	return errors.New("not implemented")
+	return newPathError("delete", strings.Join(filePaths, ", "), ErrReadOnly)
 }
 
 func (s *StaticStorage) DeleteDirectory(dirPath string) error {
-	return errors.New("not implemented")
+	return newPathError("delete", dirPath, ErrReadOnly)
 }
 
+// Directories lists the sub-directories in the specified directory,
+// as found in the manifest
 func (s *StaticStorage) Directories(dirPath string) ([]string, error) {
-	return []string{}, errors.New("not implemented")
+	return s.list("list", dirPath, true)
 }
 
+// Exists checks if the file exists with a HEAD request
 func (s *StaticStorage) Exists(filePath string) (bool, error) {
-	return false, errors.New("not implemented")
+	_, err := s.head("exists", filePath)
+
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
+// Files lists the files in the specified directory, as found in the manifest
 func (s *StaticStorage) Files(dirPath string) ([]string, error) {
-	return []string{}, errors.New("not implemented")
+	return s.list("list", dirPath, false)
 }
 
 func (s *StaticStorage) MakeDirectory(dirPath string) error {
-	return errors.New("not implemented")
+	return newPathError("mkdir", dirPath, ErrReadOnly)
 }
 
+// LastModified returns the Last-Modified header of a HEAD request
 func (s *StaticStorage) LastModified(filePath string) (time.Time, error) {
-	return time.Time{}, errors.New("not implemented")
+	resp, err := s.head("lastmodified", filePath)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	if err != nil {
+		return time.Time{}, newPathError("lastmodified", filePath, ErrNotSupported)
+	}
+
+	return lastModified, nil
 }
 
 func (s *StaticStorage) Move(originFile, targetFile string) error {
-	return errors.New("not implemented")
+	return newPathError("move", originFile, ErrReadOnly)
 }
 
+// ReadFile downloads the file with a GET request
 func (s *StaticStorage) ReadFile(filePath string) ([]byte, error) {
-	return nil, errors.New("not implemented")
+	resp, err := s.request(http.MethodGet, "read", filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, newPathError("read", filePath, err)
+	}
+
+	return data, nil
 }
 
+// Size returns the Content-Length header of a HEAD request
 func (s *StaticStorage) Size(filePath string) (int64, error) {
-	return -1, errors.New("not implemented")
+	resp, err := s.head("size", filePath)
+
+	if err != nil {
+		return -1, err
+	}
+
+	if resp.ContentLength < 0 {
+		return -1, newPathError("size", filePath, ErrNotSupported)
+	}
+
+	return resp.ContentLength, nil
 }
 
+// Url returns the URL of the file. With a manifest, the URL is of the
+// path in the manifest, i.e. "app.js" is served from "app.3f9a1c.js".
 func (s *StaticStorage) Url(filePath string) (string, error) {
-	return strings.TrimRight(s.disk.Url, "/") + "/" + strings.TrimLeft(filePath, "/"), errors.New("not implemented")
+	if s.disk.ManifestPath == "" {
+		return joinUrl(s.disk.Url, cleanPath(filePath)), nil
+	}
+
+	manifest, err := s.loadManifest("url", filePath)
+
+	if err != nil {
+		return "", err
+	}
+
+	return joinUrl(s.disk.Url, cleanPath(manifest.Path(filePath))), nil
 }
 
 func (s *StaticStorage) Put(filePath string, content []byte) error {
-	return errors.New("not implemented")
+	return newPathError("put", filePath, ErrReadOnly)
+}
+
+func (s *StaticStorage) head(op, filePath string) (*http.Response, error) {
+	resp, err := s.request(http.MethodHead, op, filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+// request sends a request for the file, and checks the response status.
+// The caller must close the body of the returned response.
+func (s *StaticStorage) request(method, op, filePath string) (*http.Response, error) {
+	fileUrl, err := s.Url(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.requestUrl(method, op, filePath, fileUrl)
+}
+
+func (s *StaticStorage) requestUrl(method, op, filePath, fileUrl string) (*http.Response, error) {
+	req, err := http.NewRequest(method, fileUrl, nil)
+
+	if err != nil {
+		return nil, newPathError(op, filePath, err)
+	}
+
+	resp, err := s.httpClient().Do(req)
+
+	if err != nil {
+		return nil, newPathError(op, filePath, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, newPathError(op, filePath, ErrNotFound)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, newPathError(op, filePath, errors.New("unexpected status: "+resp.Status))
+	}
+
+	return resp, nil
+}
+
+func (s *StaticStorage) httpClient() *http.Client {
+	if s.disk.HTTPClient != nil {
+		return s.disk.HTTPClient
+	}
+
+	return http.DefaultClient
+}
+
+// list lists the entries of a directory from the manifest, either
+// only the sub-directories or only the files
+func (s *StaticStorage) list(op, dirPath string, directories bool) ([]string, error) {
+	manifest, err := s.loadManifest(op, dirPath)
+
+	if err != nil {
+		return []string{}, err
+	}
+
+	dirPath = cleanPath(dirPath)
+	prefix := ""
+
+	if dirPath != "" {
+		prefix = dirPath + "/"
+	}
+
+	found := map[string]bool{}
+
+	for filePath := range manifest {
+		filePath = cleanPath(filePath)
+
+		if prefix != "" && !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+
+		name, rest, isDir := strings.Cut(filePath[len(prefix):], "/")
+
+		if isDir && directories {
+			found[prefix+name] = true
+		}
+
+		if !isDir && !directories && rest == "" {
+			found[filePath] = true
+		}
+	}
+
+	if len(found) == 0 && dirPath != "" && !s.manifestHasDirectory(manifest, prefix) {
+		return []string{}, newPathError(op, dirPath, ErrNotFound)
+	}
+
+	paths := []string{}
+
+	for filePath := range found {
+		paths = append(paths, filePath)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// manifestHasDirectory checks if any of the manifest files is in the directory
+func (s *StaticStorage) manifestHasDirectory(manifest Manifest, prefix string) bool {
+	for filePath := range manifest {
+		if strings.HasPrefix(cleanPath(filePath), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RefreshManifest downloads the manifest again, i.e. after the files
+// were published anew. Until refreshed, the manifest downloaded on
+// first use is kept for the life of the storage.
+func (s *StaticStorage) RefreshManifest() error {
+	if s.disk.ManifestPath == "" {
+		return newPathError("manifest", "", ErrNotSupported)
+	}
+
+	s.manifestMu.Lock()
+	defer s.manifestMu.Unlock()
+
+	manifest, err := s.downloadManifest()
+
+	if err != nil {
+		return err
+	}
+
+	s.manifest = manifest
+
+	return nil
+}
+
+// SetManifest replaces the manifest of the storage, without downloading
+// it, i.e. with the one returned by Publish
+func (s *StaticStorage) SetManifest(manifest Manifest) {
+	s.manifestMu.Lock()
+	defer s.manifestMu.Unlock()
+
+	s.manifest = manifest
+}
+
+// loadManifest downloads the manifest on first use. A failed
+// download is retried on the next call.
+func (s *StaticStorage) loadManifest(op, filePath string) (Manifest, error) {
+	if s.disk.ManifestPath == "" {
+		return nil, newPathError(op, filePath, ErrNotSupported)
+	}
+
+	s.manifestMu.Lock()
+	defer s.manifestMu.Unlock()
+
+	if s.manifest != nil {
+		return s.manifest, nil
+	}
+
+	manifest, err := s.downloadManifest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.manifest = manifest
+
+	return s.manifest, nil
+}
+
+func (s *StaticStorage) downloadManifest() (Manifest, error) {
+	// the manifest itself is not in the manifest
+	manifestUrl := joinUrl(s.disk.Url, cleanPath(s.disk.ManifestPath))
+
+	resp, err := s.requestUrl(http.MethodGet, "manifest", s.disk.ManifestPath, manifestUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	manifest := Manifest{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, newPathError("manifest", s.disk.ManifestPath, err)
+	}
+
+	return manifest, nil
 }
@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var staticStorageModified = time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+// NewStaticTestStorage serves the files over HTTP, and returns a static storage
+// reading them, i.e. for the conformance tests in the filesystem_test package
+func NewStaticTestStorage(t *testing.T, files map[string]string) StorageInterface {
+	return newStaticTestStorage(t, staticTestHandler(files), "")
+}
+
+// newStaticTestStorage serves the handler under "/assets", and returns
+// a static storage reading from it
+func newStaticTestStorage(t *testing.T, handler http.Handler, manifestPath string) StorageInterface {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return newTestStorage(t, Disk{
+		DiskName:     CDN,
+		Driver:       DRIVER_STATIC,
+		Url:          server.URL + "/assets",
+		HTTPClient:   server.Client(),
+		ManifestPath: manifestPath,
+	})
+}
+
+// staticTestHandler serves the files under "/assets", and fails
+// the requests for "broken.js" with an internal server error
+func staticTestHandler(files map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/assets/broken.js" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		content, exists := files[strings.TrimPrefix(r.URL.Path, "/assets/")]
+
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeContent(w, r, r.URL.Path, staticStorageModified, bytes.NewReader([]byte(content)))
+	})
+}
+
+// staticStorageInit returns a static storage serving a few assets, and
+// their manifest listing all the files
+func staticStorageInit(t *testing.T, manifestPath string) StorageInterface {
+	return newStaticTestStorage(t, staticTestHandler(map[string]string{
+		"app.js":           "console.log('app')",
+		"css/site.css":     "body{}",
+		"css/vendor/a.css": "a{}",
+		"manifest.json":    `["app.js", "css/site.css", "css/vendor/a.css"]`,
+	}), manifestPath)
+}
+
+func TestStaticStorageLastModified(t *testing.T) {
+	s := staticStorageInit(t, "")
+
+	modified, err := s.LastModified("app.js")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !modified.Equal(staticStorageModified) {
+		t.Fatal("unexpected last modified:", modified)
+	}
+}
+
+func TestStaticStorageStatusError(t *testing.T) {
+	s := staticStorageInit(t, "")
+
+	if _, err := s.ReadFile("broken.js"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatal("expected a status error, got:", err)
+	}
+
+	if _, err := s.Exists("broken.js"); err == nil {
+		t.Fatal("expected a status error, got nil")
+	}
+}
+
+func TestStaticStorageManifestListing(t *testing.T) {
+	s := staticStorageInit(t, "manifest.json")
+
+	files, err := s.Files("")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if strings.Join(files, ",") != "app.js" {
+		t.Fatal("unexpected files:", files)
+	}
+
+	files, err = s.Files("css")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if strings.Join(files, ",") != "css/site.css" {
+		t.Fatal("unexpected files:", files)
+	}
+
+	directories, err := s.Directories("")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if strings.Join(directories, ",") != "css" {
+		t.Fatal("unexpected directories:", directories)
+	}
+
+	if _, err := s.Files("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got:", err)
+	}
+}
+
+func TestStaticStorageMissingManifest(t *testing.T) {
+	s := staticStorageInit(t, "missing-manifest.json")
+
+	if _, err := s.Files(""); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got:", err)
+	}
+}
+
+func TestManifestUnmarshal(t *testing.T) {
+	manifest := Manifest{}
+
+	if err := manifest.UnmarshalJSON([]byte(`{"app.js": "app.3f9a1c.js"}`)); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if manifest["app.js"] != "app.3f9a1c.js" {
+		t.Fatal("unexpected manifest:", manifest)
+	}
+
+	if err := manifest.UnmarshalJSON([]byte(`{"src/main.ts": {"file": "assets/main.4f2a9c.js", "src": "src/main.ts"}}`)); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if manifest.Path("src/main.ts") != "assets/main.4f2a9c.js" {
+		t.Fatal("unexpected manifest:", manifest)
+	}
+
+	if err := manifest.UnmarshalJSON([]byte(`["app.js"]`)); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(manifest) != 1 || manifest["app.js"] != "app.js" {
+		t.Fatal("unexpected manifest:", manifest)
+	}
+
+	if err := manifest.UnmarshalJSON([]byte(`42`)); err == nil {
+		t.Fatal("expected an error for an invalid manifest")
+	}
+}
@@ -1,87 +1,59 @@
 package filesystem
 
 import (
-	// "project/config"
-
 	"errors"
-
-	"github.com/samber/lo"
+	"reflect"
 )
 
+// NewStorage creates the storage of the disk with the factory
+// of its driver, see RegisterDriver
 func NewStorage(disk Disk) (StorageInterface, error) {
-	// var disks = map[string]Disk{
-	// 	DEFAULT: {
-	// 		DiskName: DEFAULT,
-	// 		Driver:   DRIVER_S3,
-	// 		Url:                  config.MediaUrl,
-	// 		Region:               config.MediaRegion,
-	// 		Key:                  config.MediaKey,
-	// 		Secret:               config.MediaSecret,
-	// 		UsePathStyleEndpoint: true,
-	// 	},
-	// 	CDN: {
-	// 		DiskName: SQL,
-	// 		Driver:   DRIVER_SQL,
-	// 		DB:      db,
-	//      TableName: "filestore",
-	// 		Url:      config.STORAGE_URL,
-	// 	},
-	// 	CDN: {
-	// 		DiskName: CDN,
-	// 		Driver:   DRIVER_STATIC,
-	// 		Url:      config.CDN_MEDIA_URL,
-	// 	},
-	// }
+	driver, err := checkDisk(disk)
 
-	// disk := lo.ValueOr(disks, diskName, Disk{})
-
-	if lo.IsEmpty(disk) {
-		return nil, errors.New("disk cannot be empty")
+	if err != nil {
+		return nil, err
 	}
 
-	if disk.Driver == "" {
-		return nil, errors.New("driver is required field")
+	if err := driver.validate(disk); err != nil {
+		return nil, err
 	}
 
-	if disk.Url == "" {
-		return nil, errors.New("url is required field")
-	}
+	return driver.factory(disk)
+}
 
-	if disk.Driver == DRIVER_S3 && disk.Region == "" {
-		return nil, errors.New("region is required field")
-	}
+// ValidateDisk checks the disk with the rules of NewStorage, without
+// creating the storage. The fields set in code (DB and FS) are not checked.
+func ValidateDisk(disk Disk) error {
+	driver, err := checkDisk(disk)
 
-	if disk.Driver == DRIVER_S3 && disk.Key == "" {
-		return nil, errors.New("key is required field")
+	if err != nil {
+		return err
 	}
 
-	if disk.Driver == DRIVER_S3 && disk.Secret == "" {
-		return nil, errors.New("secret is required field")
+	return driver.validate(disk)
+}
+
+// checkDisk checks the fields common to all the drivers,
+// and returns the driver of the disk
+func checkDisk(disk Disk) (registeredDriver, error) {
+	// not comparing with Disk{}, as comparing an FS holding a map panics
+	if reflect.ValueOf(disk).IsZero() {
+		return registeredDriver{}, errors.New("disk cannot be empty")
 	}
 
-	if disk.Driver == DRIVER_S3 {
-		storage := &S3Storage{
-			disk: disk,
-		}
-		return storage, nil
+	if disk.Driver == "" {
+		return registeredDriver{}, errors.New("driver is required field")
 	}
 
-	if disk.Driver == DRIVER_SQL {
-		return NewSqlStorage(SqlStorageOptions{
-			DB:                 disk.DB,
-			FilestoreTable:     disk.TableName,
-			AutomigrateEnabled: true,
-			URL:                disk.Url,
-		})
+	if disk.Url == "" {
+		return registeredDriver{}, errors.New("url is required field")
 	}
 
-	if disk.Driver == DRIVER_STATIC {
-		storage := &StaticStorage{
-			disk: disk,
-		}
-		return storage, nil
+	driver, exists := findDriver(disk.Driver)
+
+	if !exists {
+		return registeredDriver{}, errors.New("driver not supported")
 	}
 
-	//storage := &OsStorage{disk: diskName}
-	return nil, errors.New("driver not supported")
+	return driver, nil
 }
@@ -0,0 +1,37 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// StorageContextInterface is implemented by the storages, which support
+// cancellation and deadlines. Each method matches the StorageInterface
+// method of the same name, with a context.Context as first argument.
+type StorageContextInterface interface {
+	StorageInterface
+	CopyContext(ctx context.Context, originFile, targetFile string) error
+	DeleteDirectoryContext(ctx context.Context, dirPath string) error
+	DeleteFileContext(ctx context.Context, filePaths []string) error
+	DirectoriesContext(ctx context.Context, dir string) ([]string, error)
+	ExistsContext(ctx context.Context, filePath string) (bool, error)
+	FilesContext(ctx context.Context, dir string) ([]string, error)
+	MakeDirectoryContext(ctx context.Context, dir string) error
+	MoveContext(ctx context.Context, originFile, targetFile string) error
+	PutContext(ctx context.Context, filePath string, content []byte) error
+	ReadFileContext(ctx context.Context, filePath string) ([]byte, error)
+	SizeContext(ctx context.Context, filePath string) (int64, error)
+	LastModifiedContext(ctx context.Context, file string) (time.Time, error)
+	UrlContext(ctx context.Context, file string) (string, error)
+
+	// The streams of StorageStreamInterface, i.e. to stop an upload when
+	// the HTTP request sending it is aborted
+	PutStreamContext(ctx context.Context, filePath string, reader io.Reader, size int64) error
+	OpenReaderContext(ctx context.Context, filePath string) (io.ReadCloser, error)
+	OpenWriterContext(ctx context.Context, filePath string) (io.WriteCloser, error)
+
+	// The ranges of StorageRangeInterface
+	ReadRangeContext(ctx context.Context, filePath string, offset, length int64) ([]byte, error)
+	OpenContext(ctx context.Context, filePath string) (io.ReadSeekCloser, error)
+}
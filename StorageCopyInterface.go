@@ -0,0 +1,26 @@
+package filesystem
+
+// CopyDirectoryOptions are the options of CopyDirectoryWithOptions.
+// Without options, the copy fails with ErrAlreadyExists when any of
+// the files already exists in the target, before copying anything.
+type CopyDirectoryOptions struct {
+	// Overwrite replaces the files, which already exist in the target
+	Overwrite bool
+
+	// SkipExisting keeps the files, which already exist in the target,
+	// and copies only the missing ones
+	SkipExisting bool
+}
+
+// StorageCopyInterface is implemented by the storages, which can
+// copy a directory together with all its contents
+type StorageCopyInterface interface {
+	StorageInterface
+
+	// CopyDirectory copies the directory and all its contents,
+	// recursively, to the target directory
+	CopyDirectory(sourceDir, targetDir string) error
+
+	// CopyDirectoryWithOptions copies the directory as CopyDirectory does, with the options
+	CopyDirectoryWithOptions(sourceDir, targetDir string, options CopyDirectoryOptions) error
+}
@@ -0,0 +1,356 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"time"
+)
+
+// AsFS adapts the storage to an fs.FS, i.e. for http.FS, template.ParseFS
+// or fs.WalkDir. The returned FS also implements fs.ReadDirFS, fs.StatFS
+// and fs.ReadFileFS. The metadata comes from Stat and List, when the storage
+// implements StorageStatInterface, otherwise from Files, Directories,
+// Size and LastModified.
+func AsFS(storage StorageInterface) fs.FS {
+	return &storageFS{storage: storage}
+}
+
+type storageFS struct {
+	storage StorageInterface
+}
+
+var _ fs.ReadDirFS = (*storageFS)(nil)  // verify it extends the read dir FS interface
+var _ fs.StatFS = (*storageFS)(nil)     // verify it extends the stat FS interface
+var _ fs.ReadFileFS = (*storageFS)(nil) // verify it extends the read file FS interface
+
+func (f *storageFS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir {
+		entries, err := f.readDir("open", name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &storageDir{info: info, name: name, entries: entries}, nil
+	}
+
+	reader, err := f.open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &storageFile{info: info, name: name, reader: reader}, nil
+}
+
+func (f *storageFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return f.readDir("readdir", name)
+}
+
+func (f *storageFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	data, err := f.storage.ReadFile(f.storagePath(name))
+
+	if err != nil {
+		return nil, fsError("readfile", name, err)
+	}
+
+	return data, nil
+}
+
+func (f *storageFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := f.stat("stat", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fsFileInfo{info: info}, nil
+}
+
+// open opens the file for reading, seekable as http.FS requires it
+func (f *storageFS) open(name string) (io.ReadSeekCloser, error) {
+	if rangeStorage, ok := f.storage.(StorageRangeInterface); ok {
+		reader, err := rangeStorage.Open(f.storagePath(name))
+
+		if err != nil {
+			return nil, fsError("open", name, err)
+		}
+
+		return reader, nil
+	}
+
+	data, err := f.storage.ReadFile(f.storagePath(name))
+
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+
+	return bytesReadSeekCloser{bytes.NewReader(data)}, nil
+}
+
+func (f *storageFS) stat(op, name string) (FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return FileInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	filePath := f.storagePath(name)
+
+	if filePath == "" {
+		return FileInfo{Name: ".", IsDir: true}, nil
+	}
+
+	if statStorage, ok := f.storage.(StorageStatInterface); ok {
+		info, err := statStorage.Stat(filePath)
+
+		if err != nil {
+			return FileInfo{}, fsError(op, name, err)
+		}
+
+		return info, nil
+	}
+
+	// without Stat, the entry is looked up in the listings of its parent
+	parent := path.Dir(name)
+
+	directories, err := f.storage.Directories(f.storagePath(parent))
+
+	if err != nil {
+		return FileInfo{}, fsError(op, name, err)
+	}
+
+	if slices.Contains(directories, filePath) {
+		return FileInfo{Name: path.Base(name), Path: filePath, IsDir: true}, nil
+	}
+
+	files, err := f.storage.Files(f.storagePath(parent))
+
+	if err != nil {
+		return FileInfo{}, fsError(op, name, err)
+	}
+
+	if !slices.Contains(files, filePath) {
+		return FileInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f.fileInfo(op, name)
+}
+
+// fileInfo builds the metadata of a file with Size and LastModified
+func (f *storageFS) fileInfo(op, name string) (FileInfo, error) {
+	filePath := f.storagePath(name)
+
+	size, err := f.storage.Size(filePath)
+
+	if err != nil {
+		return FileInfo{}, fsError(op, name, err)
+	}
+
+	modTime, err := f.storage.LastModified(filePath)
+
+	if err != nil {
+		return FileInfo{}, fsError(op, name, err)
+	}
+
+	return FileInfo{Name: path.Base(name), Path: filePath, Size: size, ModTime: modTime}, nil
+}
+
+// readDir lists the directory, sorted by file name as fs.ReadDirFS requires
+func (f *storageFS) readDir(op, name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	infos := []FileInfo{}
+
+	if statStorage, ok := f.storage.(StorageStatInterface); ok {
+		list, err := statStorage.List(f.storagePath(name))
+
+		if err != nil {
+			return nil, fsError(op, name, err)
+		}
+
+		infos = list
+	} else {
+		directories, err := f.storage.Directories(f.storagePath(name))
+
+		if err != nil {
+			return nil, fsError(op, name, err)
+		}
+
+		for _, directory := range directories {
+			infos = append(infos, FileInfo{Name: path.Base(directory), Path: directory, IsDir: true})
+		}
+
+		files, err := f.storage.Files(f.storagePath(name))
+
+		if err != nil {
+			return nil, fsError(op, name, err)
+		}
+
+		for _, file := range files {
+			info, err := f.fileInfo(op, path.Join(name, path.Base(file)))
+
+			if err != nil {
+				return nil, err
+			}
+
+			infos = append(infos, info)
+		}
+	}
+
+	entries := make([]fs.DirEntry, len(infos))
+
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(fsFileInfo{info: info})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// storagePath converts an fs path to a storage path, "." is the root
+func (f *storageFS) storagePath(name string) string {
+	if name == "." {
+		return ""
+	}
+
+	return name
+}
+
+// fsError maps the sentinel errors to the matching fs errors,
+// and wraps them in an *fs.PathError
+func fsError(op, name string, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		err = fs.ErrNotExist
+	case errors.Is(err, ErrAlreadyExists):
+		err = fs.ErrExist
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrNotSupported):
+		err = errors.Join(fs.ErrPermission, err)
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// fsFileInfo implements fs.FileInfo on top of FileInfo
+type fsFileInfo struct {
+	info FileInfo
+}
+
+func (i fsFileInfo) Name() string {
+	return i.info.Name
+}
+
+func (i fsFileInfo) Size() int64 {
+	return i.info.Size
+}
+
+func (i fsFileInfo) Mode() fs.FileMode {
+	if i.info.IsDir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+func (i fsFileInfo) ModTime() time.Time {
+	return i.info.ModTime
+}
+
+func (i fsFileInfo) IsDir() bool {
+	return i.info.IsDir
+}
+
+// Sys returns the FileInfo of the storage
+func (i fsFileInfo) Sys() any {
+	return i.info
+}
+
+// storageFile is a file opened by storageFS
+type storageFile struct {
+	info   FileInfo
+	name   string
+	reader io.ReadSeekCloser
+}
+
+func (f *storageFile) Stat() (fs.FileInfo, error) {
+	return fsFileInfo{info: f.info}, nil
+}
+
+func (f *storageFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *storageFile) Seek(offset int64, whence int) (int64, error) {
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *storageFile) ReadAt(p []byte, offset int64) (int, error) {
+	readerAt, ok := f.reader.(io.ReaderAt)
+
+	if !ok {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrNotSupported}
+	}
+
+	return readerAt.ReadAt(p, offset)
+}
+
+func (f *storageFile) Close() error {
+	return f.reader.Close()
+}
+
+// storageDir is a directory opened by storageFS
+type storageDir struct {
+	info    FileInfo
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *storageDir) Stat() (fs.FileInfo, error) {
+	return fsFileInfo{info: d.info}, nil
+}
+
+func (d *storageDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: ErrNotFile}
+}
+
+func (d *storageDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(remaining))
+	d.offset += n
+
+	return remaining[:n], nil
+}
+
+func (d *storageDir) Close() error {
+	return nil
+}
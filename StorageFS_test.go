@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func storageFSInit(t *testing.T, storage StorageInterface) fs.FS {
+	for _, dir := range []string{"dir", "dir/sub", "empty"} {
+		if err := storage.MakeDirectory(dir); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	files := map[string]string{
+		"a.txt":           "a",
+		"dir/b.txt":       "bb",
+		"dir/sub/c.html":  "<p>c</p>",
+		"dir/sub/d.empty": "",
+	}
+
+	for filePath, content := range files {
+		if err := storage.Put(filePath, []byte(content)); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	return AsFS(storage)
+}
+
+func TestAsFSMemory(t *testing.T) {
+	fsys := storageFSInit(t, NewMemoryTestStorage(t))
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.html", "dir/sub/d.empty", "empty"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsFSLocal(t *testing.T) {
+	fsys := storageFSInit(t, NewLocalTestStorage(t))
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.html", "dir/sub/d.empty", "empty"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsFSSql(t *testing.T) {
+	fsys := storageFSInit(t, NewSqlTestStorage(t))
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.html", "dir/sub/d.empty", "empty"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsFSS3(t *testing.T) {
+	storage, _ := newFakeS3Storage(t, Disk{})
+
+	fsys := storageFSInit(t, storage)
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.html", "dir/sub/d.empty", "empty"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listOnlyStorage hides the optional interfaces, to test the fallbacks of AsFS
+type listOnlyStorage struct {
+	StorageInterface
+}
+
+func TestAsFSWithoutOptionalInterfaces(t *testing.T) {
+	fsys := storageFSInit(t, listOnlyStorage{NewMemoryTestStorage(t)})
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.html", "dir/sub/d.empty", "empty"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsFSNotExist(t *testing.T) {
+	fsys := storageFSInit(t, NewMemoryTestStorage(t))
+
+	if _, err := fs.Stat(fsys, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	if _, err := fs.ReadFile(fsys, "dir/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	if _, err := fsys.Open("/a.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("expected fs.ErrInvalid, found:", err)
+	}
+}
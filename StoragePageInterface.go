@@ -0,0 +1,24 @@
+package filesystem
+
+// Page is a page of the entries of a directory, as returned by ListPage
+type Page struct {
+	Directories []string
+	Files       []string
+
+	// NextCursor is passed to ListPage to fetch the next page,
+	// it is empty when this is the last page
+	NextCursor string
+}
+
+// StoragePageInterface is implemented by the storages, which can list
+// a directory page by page, i.e. for file browsers of huge folders.
+type StoragePageInterface interface {
+	StorageInterface
+
+	// ListPage lists up to limit entries of the directory, in path order.
+	// Pass an empty cursor for the first page, and the NextCursor of the
+	// previous page for the next ones. The cursor is opaque, and only
+	// valid for the storage which returned it. A limit of 0 or less
+	// uses DEFAULT_PAGE_LIMIT.
+	ListPage(dir string, cursor string, limit int) (Page, error)
+}
@@ -0,0 +1,17 @@
+package filesystem
+
+// PutOptions are the options of PutWithOptions
+type PutOptions struct {
+	// IfNotExists only creates the file. When the file already
+	// exists, it is left unchanged, and ErrAlreadyExists is returned.
+	IfNotExists bool
+}
+
+// StoragePutInterface is implemented by the storages, which can
+// write files with options, i.e. create only writes
+type StoragePutInterface interface {
+	StorageInterface
+
+	// PutWithOptions writes the file as Put does, with the options
+	PutWithOptions(filePath string, content []byte, options PutOptions) error
+}
@@ -0,0 +1,18 @@
+package filesystem
+
+import "io"
+
+// StorageRangeInterface is implemented by the storages, which can read
+// parts of a file, i.e. for video scrubbing or resuming downloads.
+type StorageRangeInterface interface {
+	StorageInterface
+
+	// ReadRange reads length bytes of the file, starting at offset.
+	// A negative length reads to the end of the file. Less bytes are
+	// returned, if the range goes past the end of the file.
+	ReadRange(filePath string, offset, length int64) ([]byte, error)
+
+	// Open opens the file for random access reading, the caller must close it.
+	// The returned reader also implements io.ReaderAt.
+	Open(filePath string) (io.ReadSeekCloser, error)
+}
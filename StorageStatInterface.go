@@ -0,0 +1,36 @@
+package filesystem
+
+import "time"
+
+// FileInfo describes a file or a directory, as returned by Stat and List
+type FileInfo struct {
+	Name    string    // the base name, i.e. "file.txt"
+	Path    string    // the path relative to the root, i.e. "dir/file.txt"
+	Size    int64     // the size in bytes, 0 for directories
+	ModTime time.Time // the last modification time, zero for S3 directories
+	IsDir   bool
+
+	// MimeType is the stored content type where the backend keeps one,
+	// otherwise it is guessed from the extension. Empty for directories.
+	MimeType string
+
+	// ETag is the checksum stored by the backend (i.e. the S3 ETag). The
+	// backends without one return a weak ETag derived from the size and
+	// modification time, which still changes when the file changes.
+	ETag string
+
+	Visibility string // VISIBILITY_PUBLIC or VISIBILITY_PRIVATE
+}
+
+// StorageStatInterface is implemented by the storages, which can return
+// the metadata of files in a single call, or a single listing.
+type StorageStatInterface interface {
+	StorageInterface
+
+	// Stat returns the metadata of a file or a directory
+	Stat(filePath string) (FileInfo, error)
+
+	// List lists the files and sub-directories of the directory with
+	// their metadata, sorted by path
+	List(dir string) ([]FileInfo, error)
+}
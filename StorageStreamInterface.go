@@ -0,0 +1,21 @@
+package filesystem
+
+import "io"
+
+// StorageStreamInterface is implemented by the storages, which can read
+// and write files as streams, without holding the whole file in memory.
+type StorageStreamInterface interface {
+	StorageInterface
+
+	// PutStream writes the contents of the reader to the file. The size is
+	// the number of bytes the reader will return, or -1 if it is not known.
+	PutStream(filePath string, reader io.Reader, size int64) error
+
+	// OpenReader opens the file for reading, the caller must close it
+	OpenReader(filePath string) (io.ReadCloser, error)
+
+	// OpenWriter opens the file for writing. The file is written, replacing
+	// any existing one, when the writer is closed, and Close returns any
+	// error that occurred while storing it.
+	OpenWriter(filePath string) (io.WriteCloser, error)
+}
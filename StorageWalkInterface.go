@@ -0,0 +1,31 @@
+package filesystem
+
+import "io/fs"
+
+// SkipDir is returned by a WalkFunc to skip the directory. When returned
+// for a file, the remaining entries of the file's directory are skipped.
+var SkipDir = fs.SkipDir
+
+// SkipAll is returned by a WalkFunc to stop the walk, without an error
+var SkipAll = fs.SkipAll
+
+// WalkFunc is called by Walk for each file and directory. When listing
+// the root fails, it is called once with the root and the error, and
+// the error it returns is returned by Walk.
+type WalkFunc func(filePath string, isDir bool, err error) error
+
+// StorageWalkInterface is implemented by the storages, which can list
+// a directory recursively, i.e. for reports, cleanups and downloads.
+type StorageWalkInterface interface {
+	StorageInterface
+
+	// Walk calls fn for every file and directory under root (excluding root
+	// itself), depth first and in lexical order, like filepath.WalkDir
+	Walk(root string, fn WalkFunc) error
+
+	// AllFiles lists all the files in the directory and its sub-directories
+	AllFiles(dir string) ([]string, error)
+
+	// AllDirectories lists all the sub-directories of the directory, recursively
+	AllDirectories(dir string) ([]string, error)
+}
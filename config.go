@@ -0,0 +1,248 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// diskConfig holds the fields of a disk, which can be configured
+// outside of code. The rest (i.e. DB, FS or S3Client) are set in code.
+type diskConfig struct {
+	Driver          string `json:"driver" yaml:"driver"`
+	Url             string `json:"url" yaml:"url"`
+	Visibility      string `json:"visibility" yaml:"visibility"`
+	TableName       string `json:"table_name" yaml:"table_name"`
+	ChunkedContents bool   `json:"chunked_contents" yaml:"chunked_contents"`
+	ChunkSize       int    `json:"chunk_size" yaml:"chunk_size"`
+	Root            string `json:"root" yaml:"root"`
+	ManifestPath    string `json:"manifest_path" yaml:"manifest_path"`
+
+	Key                  string `json:"key" yaml:"key"`
+	Secret               string `json:"secret" yaml:"secret"`
+	Region               string `json:"region" yaml:"region"`
+	Bucket               string `json:"bucket" yaml:"bucket"`
+	Endpoint             string `json:"endpoint" yaml:"endpoint"`
+	UsePathStyleEndpoint bool   `json:"use_path_style_endpoint" yaml:"use_path_style_endpoint"`
+	MultipartThreshold   int64  `json:"multipart_threshold" yaml:"multipart_threshold"`
+	MultipartPartSize    int64  `json:"multipart_part_size" yaml:"multipart_part_size"`
+	MultipartConcurrency int    `json:"multipart_concurrency" yaml:"multipart_concurrency"`
+}
+
+// secretPattern matches the references in the values, i.e.
+// ${S3_SECRET} or ${file:/run/secrets/s3_secret}
+var secretPattern = regexp.MustCompile(`\$\{(file:)?([^}]+)\}`)
+
+// DiskFromEnv reads the disk from the environment variables starting with
+// the prefix, i.e. with the prefix "MEDIA" from MEDIA_DRIVER, MEDIA_URL,
+// MEDIA_ROOT, MEDIA_BUCKET or MEDIA_USE_PATH_STYLE_ENDPOINT. The name
+// of the disk is the prefix in lower case, unless set with MEDIA_NAME.
+func DiskFromEnv(prefix string) (Disk, error) {
+	prefix = strings.TrimSuffix(prefix, "_")
+
+	if prefix == "" {
+		return Disk{}, errors.New("prefix is required field")
+	}
+
+	config := diskConfig{}
+	value := reflect.ValueOf(&config).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		name := prefix + "_" + strings.ToUpper(value.Type().Field(i).Tag.Get("json"))
+		env, exists := os.LookupEnv(name)
+
+		if !exists {
+			continue
+		}
+
+		if err := setConfigField(value.Field(i), env); err != nil {
+			return Disk{}, errors.New(name + ": " + err.Error())
+		}
+	}
+
+	diskName := strings.ToLower(prefix)
+
+	if name, exists := os.LookupEnv(prefix + "_NAME"); exists {
+		diskName = name
+	}
+
+	return config.disk(diskName)
+}
+
+// DisksFromJSON reads the disks from a JSON object of disks by name:
+//
+//	{
+//	  "default": {"driver": "s3", "url": "https://cdn.example.com", "bucket": "media",
+//	    "region": "eu-west-1", "key": "${S3_KEY}", "secret": "${file:/run/secrets/s3_secret}"},
+//	  "local": {"driver": "local", "url": "http://localhost/files", "root": "/var/files"}
+//	}
+//
+// The names are the keys, so the disks can be registered with a Manager as they are.
+func DisksFromJSON(reader io.Reader) (map[string]Disk, error) {
+	configs := map[string]diskConfig{}
+
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&configs); err != nil {
+		return nil, err
+	}
+
+	return configDisks(configs)
+}
+
+// DisksFromYAML reads the disks from a YAML mapping of disks by name,
+// with the same fields as DisksFromJSON
+func DisksFromYAML(reader io.Reader) (map[string]Disk, error) {
+	configs := map[string]diskConfig{}
+
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&configs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return configDisks(configs)
+}
+
+func configDisks(configs map[string]diskConfig) (map[string]Disk, error) {
+	names := []string{}
+
+	for name := range configs {
+		names = append(names, name)
+	}
+
+	// sorted, so the same configuration reports the same error
+	sort.Strings(names)
+
+	disks := map[string]Disk{}
+
+	for _, name := range names {
+		disk, err := configs[name].disk(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		disks[name] = disk
+	}
+
+	return disks, nil
+}
+
+// disk resolves the references in the values,
+// and returns the validated disk
+func (c diskConfig) disk(diskName string) (Disk, error) {
+	value := reflect.ValueOf(&c).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Kind() != reflect.String {
+			continue
+		}
+
+		resolved, err := resolveSecrets(value.Field(i).String())
+
+		if err != nil {
+			return Disk{}, errors.New("disk " + diskName + ": " + err.Error())
+		}
+
+		value.Field(i).SetString(resolved)
+	}
+
+	disk := Disk{
+		DiskName:             diskName,
+		Driver:               c.Driver,
+		Url:                  c.Url,
+		Visibility:           c.Visibility,
+		TableName:            c.TableName,
+		ChunkedContents:      c.ChunkedContents,
+		ChunkSize:            c.ChunkSize,
+		Root:                 c.Root,
+		ManifestPath:         c.ManifestPath,
+		Key:                  c.Key,
+		Secret:               c.Secret,
+		Region:               c.Region,
+		Bucket:               c.Bucket,
+		Endpoint:             c.Endpoint,
+		UsePathStyleEndpoint: c.UsePathStyleEndpoint,
+		MultipartThreshold:   c.MultipartThreshold,
+		MultipartPartSize:    c.MultipartPartSize,
+		MultipartConcurrency: c.MultipartConcurrency,
+	}
+
+	if err := ValidateDisk(disk); err != nil {
+		return Disk{}, errors.New("disk " + diskName + ": " + err.Error())
+	}
+
+	return disk, nil
+}
+
+// resolveSecrets replaces the ${ENV_VAR} references with the value of the
+// environment variable, and the ${file:path} references with the content
+// of the file, without the trailing new line
+func resolveSecrets(value string) (string, error) {
+	var err error
+
+	resolved := secretPattern.ReplaceAllStringFunc(value, func(reference string) string {
+		match := secretPattern.FindStringSubmatch(reference)
+
+		if match[1] != "" {
+			data, readErr := os.ReadFile(match[2])
+
+			if readErr != nil && err == nil {
+				err = readErr
+			}
+
+			return strings.TrimRight(string(data), "\r\n")
+		}
+
+		env, exists := os.LookupEnv(match[2])
+
+		if !exists && err == nil {
+			err = errors.New("environment variable " + match[2] + " is not set")
+		}
+
+		return env
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return resolved, nil
+}
+
+// setConfigField parses the environment variable into the field
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(parsed)
+	default:
+		field.SetString(value)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiskFromEnv(t *testing.T) {
+	t.Setenv("MEDIA_DRIVER", DRIVER_S3)
+	t.Setenv("MEDIA_URL", "https://cdn.example.com")
+	t.Setenv("MEDIA_REGION", "eu-west-1")
+	t.Setenv("MEDIA_KEY", "key")
+	t.Setenv("MEDIA_SECRET", "${TEST_S3_SECRET}")
+	t.Setenv("MEDIA_USE_PATH_STYLE_ENDPOINT", "true")
+	t.Setenv("MEDIA_MULTIPART_CONCURRENCY", "8")
+	t.Setenv("TEST_S3_SECRET", "secret")
+
+	disk, err := DiskFromEnv("MEDIA")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if disk.DiskName != "media" || disk.Driver != DRIVER_S3 || disk.Region != "eu-west-1" {
+		t.Fatal("unexpected disk:", disk)
+	}
+
+	if disk.Secret != "secret" {
+		t.Fatal("unexpected secret:", disk.Secret)
+	}
+
+	if !disk.UsePathStyleEndpoint || disk.MultipartConcurrency != 8 {
+		t.Fatal("unexpected disk:", disk)
+	}
+
+	t.Setenv("MEDIA_NAME", DEFAULT)
+
+	disk, err = DiskFromEnv("MEDIA_")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if disk.DiskName != DEFAULT {
+		t.Fatal("unexpected disk name:", disk.DiskName)
+	}
+}
+
+func TestDiskFromEnvErrors(t *testing.T) {
+	t.Setenv("BROKEN_DRIVER", DRIVER_S3)
+	t.Setenv("BROKEN_URL", "https://cdn.example.com")
+
+	if _, err := DiskFromEnv("BROKEN"); err == nil || !strings.Contains(err.Error(), "region is required field") {
+		t.Fatal("expected the region error, got:", err)
+	}
+
+	t.Setenv("BROKEN_MULTIPART_CONCURRENCY", "many")
+
+	if _, err := DiskFromEnv("BROKEN"); err == nil || !strings.Contains(err.Error(), "BROKEN_MULTIPART_CONCURRENCY") {
+		t.Fatal("expected a parse error, got:", err)
+	}
+
+	t.Setenv("MISSING_DRIVER", DRIVER_LOCAL)
+	t.Setenv("MISSING_URL", "http://localhost/files")
+	t.Setenv("MISSING_ROOT", "${TEST_MISSING_ROOT}")
+
+	if _, err := DiskFromEnv("MISSING"); err == nil || !strings.Contains(err.Error(), "TEST_MISSING_ROOT is not set") {
+		t.Fatal("expected an unset variable error, got:", err)
+	}
+}
+
+func TestDisksFromJSON(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	t.Setenv("TEST_FILES_ROOT", "/var/files")
+
+	disks, err := DisksFromJSON(strings.NewReader(`{
+		"default": {"driver": "s3", "url": "https://cdn.example.com", "region": "eu-west-1",
+			"key": "key", "secret": "${file:` + filepath.ToSlash(secretFile) + `}"},
+		"files": {"driver": "local", "url": "http://localhost/files", "root": "${TEST_FILES_ROOT}/public"}
+	}`))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if disks[DEFAULT].DiskName != DEFAULT || disks[DEFAULT].Secret != "file-secret" {
+		t.Fatal("unexpected disk:", disks[DEFAULT])
+	}
+
+	if disks["files"].Root != "/var/files/public" {
+		t.Fatal("unexpected root:", disks["files"].Root)
+	}
+
+	manager, err := NewManager(disks["files"], disks[DEFAULT])
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if strings.Join(manager.Names(), ",") != "default,files" {
+		t.Fatal("unexpected names:", manager.Names())
+	}
+
+	if _, err := DisksFromJSON(strings.NewReader(`{"files": {"driver": "local", "url": "http://localhost", "rot": "/tmp"}}`)); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+
+	if _, err := DisksFromJSON(strings.NewReader(`{"files": {"driver": "local", "url": "http://localhost"}}`)); err == nil || err.Error() != "disk files: root is required field" {
+		t.Fatal("expected the root error, got:", err)
+	}
+}
+
+func TestDisksFromYAML(t *testing.T) {
+	disks, err := DisksFromYAML(strings.NewReader(`
+default:
+  driver: s3
+  url: https://cdn.example.com
+  region: eu-west-1
+  key: key
+  secret: secret
+  use_path_style_endpoint: true
+sql:
+  driver: sql
+  url: https://example.com/files
+  table_name: files
+`))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !disks[DEFAULT].UsePathStyleEndpoint || disks[DEFAULT].Secret != "secret" {
+		t.Fatal("unexpected disk:", disks[DEFAULT])
+	}
+
+	if disks["sql"].TableName != "files" || disks["sql"].DiskName != "sql" {
+		t.Fatal("unexpected disk:", disks["sql"])
+	}
+
+	if _, err := DisksFromYAML(strings.NewReader("cdn:\n  driver: ftp\n  url: https://cdn.example.com\n")); err == nil || err.Error() != "disk cdn: driver not supported" {
+		t.Fatal("expected driver not supported, got:", err)
+	}
+}
@@ -3,9 +3,21 @@ package filesystem
 const DEFAULT = "default"
 const CDN = "cdn"
 
+const DRIVER_FS = "fs"
+const DRIVER_LOCAL = "local"
+const DRIVER_MEMORY = "memory"
 const DRIVER_S3 = "s3"
 const DRIVER_SQL = "sql"
 const DRIVER_STATIC = "static"
 
+const VISIBILITY_PUBLIC = "public"
+const VISIBILITY_PRIVATE = "private"
+
 const PATH_SEPARATOR = "/"
 const ROOT_PATH = PATH_SEPARATOR
+
+// DEFAULT_PAGE_LIMIT is the page size used, when ListPage is called without a limit
+const DEFAULT_PAGE_LIMIT = 1000
+
+// DEFAULT_SQL_CHUNK_SIZE is the size of the chunks, the SQL storage stores chunked contents in
+const DEFAULT_SQL_CHUNK_SIZE = 256 * 1024
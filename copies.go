@@ -0,0 +1,128 @@
+package filesystem
+
+import (
+	"errors"
+	"strings"
+)
+
+// copyEntry is a file, or a directory, to copy to the target
+type copyEntry struct {
+	source string
+	target string
+	isDir  bool
+}
+
+// checkCopyDirectory checks the options and the paths of a directory
+// copy, returning the cleaned source and target directories
+func checkCopyDirectory(sourceDir, targetDir string, options CopyDirectoryOptions) (string, string, error) {
+	if options.Overwrite && options.SkipExisting {
+		return "", "", newPathError("copy", sourceDir, errors.New("overwrite and skip existing can not be used together"))
+	}
+
+	sourceDir = cleanPath(sourceDir)
+	targetDir = cleanPath(targetDir)
+
+	if sourceDir == targetDir || sourceDir == "" || strings.HasPrefix(targetDir, sourceDir+PATH_SEPARATOR) {
+		return "", "", newPathError("copy", targetDir, errors.New("can not copy a directory into itself"))
+	}
+
+	return sourceDir, targetDir, nil
+}
+
+// copyTarget maps a path under the source directory to the target directory
+func copyTarget(sourceDir, targetDir, sourcePath string) string {
+	return cleanPath(targetDir + PATH_SEPARATOR + strings.TrimPrefix(sourcePath, sourceDir+PATH_SEPARATOR))
+}
+
+// copyDirectory copies the directory with the walk of the storage. The
+// directories are made first, parents before children, and then the
+// files are copied one at a time. The existing files are found up front,
+// so without options nothing is copied when any of them exists.
+func copyDirectory(storage StorageWalkInterface, sourceDir, targetDir string, options CopyDirectoryOptions, makeDirectory func(dir string) error, copyFile func(source, target string) error) error {
+	sourceDir, targetDir, err := checkCopyDirectory(sourceDir, targetDir, options)
+
+	if err != nil {
+		return err
+	}
+
+	entries := []copyEntry{{source: sourceDir, target: targetDir, isDir: true}}
+
+	err = storage.Walk(sourceDir, func(filePath string, isDir bool, err error) error {
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, copyEntry{
+			source: filePath,
+			target: copyTarget(sourceDir, targetDir, filePath),
+			isDir:  isDir,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	existing, err := existingFiles(storage, targetDir)
+
+	if err != nil {
+		return err
+	}
+
+	directories := []copyEntry{}
+	files := []copyEntry{}
+
+	for _, entry := range entries {
+		if entry.isDir {
+			directories = append(directories, entry)
+			continue
+		}
+
+		if existing[entry.target] && options.SkipExisting {
+			continue
+		}
+
+		if existing[entry.target] && !options.Overwrite {
+			return newPathError("copy", entry.target, ErrAlreadyExists)
+		}
+
+		files = append(files, entry)
+	}
+
+	for _, directory := range directories {
+		if err := makeDirectory(directory.target); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		if err := copyFile(file.source, file.target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// existingFiles finds the files under the directory, which is empty when missing
+func existingFiles(storage StorageWalkInterface, dir string) (map[string]bool, error) {
+	files, err := storage.AllFiles(dir)
+
+	if errors.Is(err, ErrNotFound) {
+		return map[string]bool{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		existing[cleanPath(file)] = true
+	}
+
+	return existing, nil
+}
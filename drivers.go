@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"sort"
+	"sync"
+)
+
+// DriverFactory creates the storage of a disk. The disk is already checked
+// to have a driver and a URL, and by the validators of its driver.
+type DriverFactory func(disk Disk) (StorageInterface, error)
+
+// DiskValidator checks the driver specific fields of a disk, without
+// creating the storage, so the disks configured outside of code (see
+// DisksFromJSON) can be checked up front. The fields set in code (i.e.
+// DB and FS) are not for the validator to check.
+type DiskValidator func(disk Disk) error
+
+// registeredDriver is the factory of a driver, with its validators
+type registeredDriver struct {
+	factory    DriverFactory
+	validators []DiskValidator
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]registeredDriver{}
+)
+
+func init() {
+	RegisterDriver(DRIVER_FS, fsDriver)
+	RegisterDriver(DRIVER_LOCAL, localDriver, validateLocalDisk)
+	RegisterDriver(DRIVER_MEMORY, memoryDriver)
+	RegisterDriver(DRIVER_S3, s3Driver, validateS3Disk)
+	RegisterDriver(DRIVER_SQL, sqlDriver, validateSqlDisk)
+	RegisterDriver(DRIVER_STATIC, staticDriver)
+}
+
+// RegisterDriver makes a driver available to NewStorage by the name,
+// i.e. from the init function of the package implementing it. The
+// optional validators check the disks of the driver, both in NewStorage
+// and in ValidateDisk. As with database/sql.Register, it panics if the
+// factory is nil, or if a driver is registered twice under the same name.
+func RegisterDriver(name string, factory func(Disk) (StorageInterface, error), validators ...DiskValidator) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("filesystem: RegisterDriver factory is nil")
+	}
+
+	if _, exists := drivers[name]; exists {
+		panic("filesystem: RegisterDriver called twice for driver " + name)
+	}
+
+	drivers[name] = registeredDriver{factory: factory, validators: validators}
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := []string{}
+
+	for name := range drivers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func findDriver(name string) (registeredDriver, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	driver, exists := drivers[name]
+
+	return driver, exists
+}
+
+// validate checks the disk with the validators of the driver
+func (d registeredDriver) validate(disk Disk) error {
+	for _, validate := range d.validators {
+		if err := validate(disk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package filesystem
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterDriver(t *testing.T) {
+	RegisterDriver("test-memory", func(disk Disk) (StorageInterface, error) {
+		disk.Url = "http://localhost/test"
+		return memoryDriver(disk)
+	})
+
+	t.Cleanup(func() { // so the test can run again, as with -count
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		delete(drivers, "test-memory")
+	})
+
+	storage, err := NewStorage(Disk{DiskName: "test", Driver: "test-memory", Url: "http://localhost/media"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	url, err := storage.Url("file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if url != "http://localhost/test/file.txt" {
+		t.Fatal("unexpected url:", url)
+	}
+
+	if !strings.Contains(strings.Join(Drivers(), ","), "test-memory") {
+		t.Fatal("unexpected drivers:", Drivers())
+	}
+}
+
+func TestRegisterDriverValidator(t *testing.T) {
+	RegisterDriver("test-validated", memoryDriver, func(disk Disk) error {
+		if disk.Root == "" {
+			return errors.New("root is required field")
+		}
+
+		return nil
+	})
+
+	t.Cleanup(func() { // so the test can run again, as with -count
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		delete(drivers, "test-validated")
+	})
+
+	disk := Disk{DiskName: "test", Driver: "test-validated", Url: "http://localhost/media"}
+
+	if err := ValidateDisk(disk); err == nil || err.Error() != "root is required field" {
+		t.Fatal("expected root is required field, got:", err)
+	}
+
+	if _, err := NewStorage(disk); err == nil || err.Error() != "root is required field" {
+		t.Fatal("expected root is required field, got:", err)
+	}
+
+	disk.Root = "root"
+
+	if err := ValidateDisk(disk); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestRegisterDriverTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a driver registered twice")
+		}
+	}()
+
+	RegisterDriver(DRIVER_MEMORY, memoryDriver)
+}
+
+func TestDrivers(t *testing.T) {
+	drivers := strings.Join(Drivers(), ",")
+
+	for _, driver := range []string{DRIVER_FS, DRIVER_LOCAL, DRIVER_MEMORY, DRIVER_S3, DRIVER_SQL, DRIVER_STATIC} {
+		if !strings.Contains(drivers, driver) {
+			t.Fatal("expected driver to be registered:", driver)
+		}
+	}
+
+	if _, err := NewStorage(Disk{Driver: "missing", Url: "http://localhost"}); err == nil || err.Error() != "driver not supported" {
+		t.Fatal("expected driver not supported, got:", err)
+	}
+
+	if _, err := NewStorage(Disk{Driver: DRIVER_S3, Url: "http://localhost"}); err == nil || err.Error() != "region is required field" {
+		t.Fatal("expected region is required field, got:", err)
+	}
+}
@@ -0,0 +1,63 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// Sentinel errors returned by all drivers. Use errors.Is to check for them,
+// as drivers usually wrap them in a *PathError.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrNotDirectory  = errors.New("not a directory")
+	ErrNotFile       = errors.New("not a file")
+	ErrNotSupported  = errors.New("not supported")
+	ErrReadOnly      = errors.New("read only storage")
+)
+
+// PathError records an error and the operation and path that caused it
+type PathError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// newPathError wraps the error in a *PathError, a nil error stays nil
+func newPathError(op, filePath string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PathError{Op: op, Path: filePath, Err: err}
+}
+
+// osError maps an error returned by the os package to
+// the matching sentinel error, and wraps it in a *PathError
+func osError(op, filePath string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		err = ErrNotFound
+	case errors.Is(err, fs.ErrExist):
+		err = ErrAlreadyExists
+	case errors.Is(err, syscall.ENOTDIR):
+		err = ErrNotDirectory
+	case errors.Is(err, syscall.EISDIR):
+		err = ErrNotFile
+	}
+
+	return newPathError(op, filePath, err)
+}
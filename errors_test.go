@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPathError(t *testing.T) {
+	err := newPathError("read", "dir/test.txt", ErrNotFound)
+
+	if err.Error() != "read dir/test.txt: not found" {
+		t.Fatal("unexpected error message:", err.Error())
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected error to match ErrNotFound")
+	}
+
+	var pathErr *PathError
+
+	if !errors.As(err, &pathErr) {
+		t.Fatal("expected error to be a *PathError")
+	}
+
+	if pathErr.Op != "read" || pathErr.Path != "dir/test.txt" {
+		t.Fatal("unexpected path error:", pathErr.Op, pathErr.Path)
+	}
+
+	if newPathError("read", "dir/test.txt", nil) != nil {
+		t.Fatal("expected nil error to stay nil")
+	}
+}
+
+func TestOsError(t *testing.T) {
+	_, err := os.ReadFile(t.TempDir() + "/missing.txt")
+
+	if !errors.Is(osError("read", "missing.txt", err), ErrNotFound) {
+		t.Fatal("expected error to match ErrNotFound")
+	}
+
+	_, err = os.ReadFile(t.TempDir())
+
+	if !errors.Is(osError("read", "", err), ErrNotFile) {
+		t.Fatal("expected error to match ErrNotFile")
+	}
+}
+
+func TestStaticStorageErrors(t *testing.T) {
+	storage, err := NewStorage(Disk{
+		DiskName: CDN,
+		Driver:   DRIVER_STATIC,
+		Url:      "https://cdn.example.com",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storage.Put("test.txt", []byte("test")); !errors.Is(err, ErrReadOnly) {
+		t.Fatal("expected ErrReadOnly, got:", err)
+	}
+
+	if _, err := storage.Files(""); !errors.Is(err, ErrNotSupported) {
+		t.Fatal("expected ErrNotSupported, got:", err)
+	}
+
+	url, err := storage.Url("/test.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if url != "https://cdn.example.com/test.txt" {
+		t.Fatal("unexpected url:", url)
+	}
+}
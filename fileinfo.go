@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// mimeTypeByPath guesses the MIME type of a file from its extension
+func mimeTypeByPath(filePath string) string {
+	mimeType := mime.TypeByExtension(path.Ext(filePath))
+
+	if mimeType == "" {
+		return "application/octet-stream"
+	}
+
+	return mimeType
+}
+
+// weakETag derives an ETag from the size and the modification time,
+// for the backends which do not store a checksum
+func weakETag(size int64, modTime time.Time) string {
+	return `W/"` + strconv.FormatInt(modTime.UnixNano(), 16) + "-" + strconv.FormatInt(size, 16) + `"`
+}
+
+// diskVisibility returns the visibility of the disk, public by default
+func diskVisibility(disk Disk) string {
+	if disk.Visibility == "" {
+		return VISIBILITY_PUBLIC
+	}
+
+	return disk.Visibility
+}
+
+// newFileInfo builds the metadata of a file or directory, guessing the
+// MIME type from the extension and deriving a weak ETag
+func newFileInfo(visibility string, filePath string, isDir bool, size int64, modTime time.Time) FileInfo {
+	info := FileInfo{
+		Name:       path.Base(filePath),
+		Path:       filePath,
+		Size:       size,
+		ModTime:    modTime,
+		IsDir:      isDir,
+		Visibility: visibility,
+	}
+
+	if filePath == "" {
+		info.Name = ""
+	}
+
+	if !isDir {
+		info.MimeType = mimeTypeByPath(filePath)
+		info.ETag = weakETag(size, modTime)
+	}
+
+	return info
+}
+
+// sortFileInfos sorts the file infos by path
+func sortFileInfos(infos []FileInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+}
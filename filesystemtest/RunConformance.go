@@ -0,0 +1,903 @@
+// Package filesystemtest provides a conformance test suite, which
+// verifies that a storage driver behaves the same way as the
+// built-in drivers of the filesystem package.
+//
+// Usage:
+//
+//	func TestConformance(t *testing.T) {
+//		filesystemtest.RunConformance(t, func(t *testing.T) filesystem.StorageInterface {
+//			return newMyStorage(t)
+//		})
+//	}
+package filesystemtest
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gouniverse/filesystem"
+)
+
+// Factory creates a new, empty storage for a single test.
+// Use t.Cleanup to release any resources held by the storage.
+type Factory func(t *testing.T) filesystem.StorageInterface
+
+// RunConformance runs the conformance test suite against the storages
+// created by the factory. Every check runs as a sub-test with its own
+// fresh storage.
+//
+// Paths are expected to be canonical: relative to the root, without
+// leading or trailing slashes. A leading slash in an argument is
+// accepted and ignored.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, storage filesystem.StorageInterface)
+	}{
+		{"PutAndReadFile", testPutAndReadFile},
+		{"Overwrite", testOverwrite},
+		{"PutIfNotExists", testPutIfNotExists},
+		{"LeadingSlash", testLeadingSlash},
+		{"NestedDirectories", testNestedDirectories},
+		{"FilesAndDirectories", testFilesAndDirectories},
+		{"Copy", testCopy},
+		{"CopyDirectory", testCopyDirectory},
+		{"Move", testMove},
+		{"MoveOntoExisting", testMoveOntoExisting},
+		{"MoveDirectory", testMoveDirectory},
+		{"MoveIntoItself", testMoveIntoItself},
+		{"DeleteFile", testDeleteFile},
+		{"DeleteDirectory", testDeleteDirectory},
+		{"ExistsMissing", testExistsMissing},
+		{"NotFoundErrors", testNotFoundErrors},
+		{"Size", testSize},
+		{"LastModified", testLastModified},
+		{"Stream", testStream},
+		{"Range", testRange},
+		{"ListPage", testListPage},
+		{"Walk", testWalk},
+		{"Stat", testStat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := factory(t)
+
+			if storage == nil {
+				t.Fatal("factory returned nil storage")
+			}
+
+			test.fn(t, storage)
+		})
+	}
+}
+
+func testPutAndReadFile(t *testing.T, storage filesystem.StorageInterface) {
+	mustPut(t, storage, "test.txt", "hello world")
+
+	assertContent(t, storage, "test.txt", "hello world")
+}
+
+func testOverwrite(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "dir")
+	mustPut(t, storage, "dir/test.txt", "first version")
+	mustPut(t, storage, "dir/test.txt", "second")
+
+	assertContent(t, storage, "dir/test.txt", "second")
+
+	files, err := storage.Files("dir")
+
+	if err != nil {
+		t.Fatal("Files() unexpected error:", err)
+	}
+
+	assertPaths(t, "Files()", files, []string{"dir/test.txt"})
+
+	size, err := storage.Size("dir/test.txt")
+
+	if err != nil {
+		t.Fatal("Size() unexpected error:", err)
+	}
+
+	if size != 6 {
+		t.Fatal("Size() expected 6 after overwrite, got:", size)
+	}
+}
+
+func testPutIfNotExists(t *testing.T, storage filesystem.StorageInterface) {
+	putStorage, ok := storage.(filesystem.StoragePutInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StoragePutInterface")
+	}
+
+	options := filesystem.PutOptions{IfNotExists: true}
+
+	if err := putStorage.PutWithOptions("test.txt", []byte("first"), options); err != nil {
+		t.Fatal("PutWithOptions() unexpected error:", err)
+	}
+
+	err := putStorage.PutWithOptions("test.txt", []byte("second"), options)
+
+	if !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Fatal("PutWithOptions() expected ErrAlreadyExists, got:", err)
+	}
+
+	assertContent(t, storage, "test.txt", "first")
+
+	if err := putStorage.PutWithOptions("test.txt", []byte("third"), filesystem.PutOptions{}); err != nil {
+		t.Fatal("PutWithOptions() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "test.txt", "third")
+}
+
+func testLeadingSlash(t *testing.T, storage filesystem.StorageInterface) {
+	mustPut(t, storage, "/test.txt", "test")
+
+	assertContent(t, storage, "test.txt", "test")
+	assertContent(t, storage, "/test.txt", "test")
+
+	files, err := storage.Files("/")
+
+	if err != nil {
+		t.Fatal("Files() unexpected error:", err)
+	}
+
+	assertPaths(t, "Files()", files, []string{"test.txt"})
+}
+
+func testNestedDirectories(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "a")
+	mustMakeDirectory(t, storage, "a/b")
+	mustPut(t, storage, "a/b/c.txt", "nested")
+
+	assertContent(t, storage, "a/b/c.txt", "nested")
+	assertExists(t, storage, "a/b/c.txt", true)
+
+	files, err := storage.Files("a/b")
+
+	if err != nil {
+		t.Fatal("Files() unexpected error:", err)
+	}
+
+	assertPaths(t, "Files()", files, []string{"a/b/c.txt"})
+}
+
+func testFilesAndDirectories(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "dir")
+	mustMakeDirectory(t, storage, "dir/sub1")
+	mustMakeDirectory(t, storage, "dir/sub2")
+	mustPut(t, storage, "dir/a.txt", "a")
+	mustPut(t, storage, "dir/b.txt", "b")
+	mustPut(t, storage, "dir/sub1/c.txt", "c")
+
+	files, err := storage.Files("dir")
+
+	if err != nil {
+		t.Fatal("Files() unexpected error:", err)
+	}
+
+	assertPaths(t, "Files()", files, []string{"dir/a.txt", "dir/b.txt"})
+
+	directories, err := storage.Directories("dir")
+
+	if err != nil {
+		t.Fatal("Directories() unexpected error:", err)
+	}
+
+	assertPaths(t, "Directories()", directories, []string{"dir/sub1", "dir/sub2"})
+
+	rootDirectories, err := storage.Directories("")
+
+	if err != nil {
+		t.Fatal("Directories() unexpected error:", err)
+	}
+
+	assertPaths(t, "Directories()", rootDirectories, []string{"dir"})
+}
+
+func testCopy(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "dir")
+	mustPut(t, storage, "a.txt", "copy me")
+
+	if err := storage.Copy("a.txt", "dir/b.txt"); err != nil {
+		t.Fatal("Copy() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "a.txt", "copy me")
+	assertContent(t, storage, "dir/b.txt", "copy me")
+}
+
+func testCopyDirectory(t *testing.T, storage filesystem.StorageInterface) {
+	copyStorage, ok := storage.(filesystem.StorageCopyInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StorageCopyInterface")
+	}
+
+	mustPut(t, storage, "dir/a.txt", "a")
+	mustPut(t, storage, "dir/sub/b.txt", "b")
+	mustMakeDirectory(t, storage, "dir/empty")
+
+	if err := copyStorage.CopyDirectory("dir", "copy"); err != nil {
+		t.Fatal("CopyDirectory() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "copy/a.txt", "a")
+	assertContent(t, storage, "copy/sub/b.txt", "b")
+	assertContent(t, storage, "dir/a.txt", "a")
+
+	directories, err := storage.Directories("copy")
+
+	if err != nil {
+		t.Fatal("Directories() unexpected error:", err)
+	}
+
+	assertPaths(t, "Directories()", directories, []string{"copy/empty", "copy/sub"})
+
+	mustPut(t, storage, "dir/a.txt", "changed")
+	mustPut(t, storage, "dir/c.txt", "c")
+
+	err = copyStorage.CopyDirectory("dir", "copy")
+
+	if !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Fatal("CopyDirectory() expected ErrAlreadyExists, got:", err)
+	}
+
+	assertExists(t, storage, "copy/c.txt", false)
+
+	if err := copyStorage.CopyDirectoryWithOptions("dir", "copy", filesystem.CopyDirectoryOptions{SkipExisting: true}); err != nil {
+		t.Fatal("CopyDirectoryWithOptions() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "copy/a.txt", "a")
+	assertContent(t, storage, "copy/c.txt", "c")
+
+	if err := copyStorage.CopyDirectoryWithOptions("dir", "copy", filesystem.CopyDirectoryOptions{Overwrite: true}); err != nil {
+		t.Fatal("CopyDirectoryWithOptions() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "copy/a.txt", "changed")
+
+	files, err := storage.Files("copy")
+
+	if err != nil {
+		t.Fatal("Files() unexpected error:", err)
+	}
+
+	assertPaths(t, "Files()", files, []string{"copy/a.txt", "copy/c.txt"})
+
+	if err := copyStorage.CopyDirectory("dir", "dir/inner"); err == nil {
+		t.Fatal("CopyDirectory() expected an error for a copy into itself")
+	}
+
+	if err := copyStorage.CopyDirectory("missing", "target"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("CopyDirectory() expected ErrNotFound, got:", err)
+	}
+}
+
+func testMove(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "dir")
+	mustPut(t, storage, "a.txt", "move me")
+
+	if err := storage.Move("a.txt", "dir/b.txt"); err != nil {
+		t.Fatal("Move() unexpected error:", err)
+	}
+
+	assertExists(t, storage, "a.txt", false)
+	assertContent(t, storage, "dir/b.txt", "move me")
+}
+
+func testMoveOntoExisting(t *testing.T, storage filesystem.StorageInterface) {
+	mustPut(t, storage, "a.txt", "a")
+	mustPut(t, storage, "b.txt", "b")
+
+	if err := storage.Move("a.txt", "b.txt"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Fatal("Move() expected ErrAlreadyExists, got:", err)
+	}
+
+	assertContent(t, storage, "a.txt", "a")
+	assertContent(t, storage, "b.txt", "b")
+}
+
+func testMoveDirectory(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "dir")
+	mustMakeDirectory(t, storage, "dir/empty")
+	mustPut(t, storage, "dir/a.txt", "a")
+
+	assertExists(t, storage, "dir", true)
+	assertExists(t, storage, "dir/empty", true)
+
+	if err := storage.Move("dir", "moved"); err != nil {
+		t.Fatal("Move() unexpected error:", err)
+	}
+
+	assertExists(t, storage, "dir", false)
+	assertExists(t, storage, "dir/a.txt", false)
+	assertExists(t, storage, "moved", true)
+	assertExists(t, storage, "moved/empty", true)
+	assertContent(t, storage, "moved/a.txt", "a")
+}
+
+func testMoveIntoItself(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "a")
+	mustMakeDirectory(t, storage, "a/b")
+	mustPut(t, storage, "a/b/c.txt", "c")
+
+	if err := storage.Move("a", "a/b/a"); err == nil {
+		t.Fatal("Move() expected an error moving a directory inside itself")
+	}
+
+	assertContent(t, storage, "a/b/c.txt", "c")
+	assertExists(t, storage, "a/b/a", false)
+}
+
+func testDeleteFile(t *testing.T, storage filesystem.StorageInterface) {
+	mustPut(t, storage, "a.txt", "a")
+	mustPut(t, storage, "b.txt", "b")
+	mustPut(t, storage, "c.txt", "c")
+
+	if err := storage.DeleteFile([]string{"a.txt", "b.txt"}); err != nil {
+		t.Fatal("DeleteFile() unexpected error:", err)
+	}
+
+	assertExists(t, storage, "a.txt", false)
+	assertExists(t, storage, "b.txt", false)
+	assertExists(t, storage, "c.txt", true)
+}
+
+func testDeleteDirectory(t *testing.T, storage filesystem.StorageInterface) {
+	mustMakeDirectory(t, storage, "dir")
+	mustMakeDirectory(t, storage, "dir/sub")
+	mustPut(t, storage, "dir/a.txt", "a")
+	mustPut(t, storage, "dir/sub/b.txt", "b")
+	mustPut(t, storage, "keep.txt", "keep")
+	mustPut(t, storage, "dirty.txt", "shares the prefix of the directory")
+
+	if err := storage.DeleteDirectory("dir"); err != nil {
+		t.Fatal("DeleteDirectory() unexpected error:", err)
+	}
+
+	assertExists(t, storage, "dir/a.txt", false)
+	assertExists(t, storage, "dir/sub/b.txt", false)
+	assertExists(t, storage, "keep.txt", true)
+	assertExists(t, storage, "dirty.txt", true)
+
+	directories, err := storage.Directories("")
+
+	if err != nil {
+		t.Fatal("Directories() unexpected error:", err)
+	}
+
+	assertPaths(t, "Directories()", directories, []string{})
+}
+
+func testExistsMissing(t *testing.T, storage filesystem.StorageInterface) {
+	assertExists(t, storage, "missing.txt", false)
+	assertExists(t, storage, "missing/missing.txt", false)
+}
+
+func testNotFoundErrors(t *testing.T, storage filesystem.StorageInterface) {
+	if _, err := storage.ReadFile("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("ReadFile() expected ErrNotFound, got:", err)
+	}
+
+	if _, err := storage.Size("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("Size() expected ErrNotFound, got:", err)
+	}
+
+	if _, err := storage.LastModified("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("LastModified() expected ErrNotFound, got:", err)
+	}
+
+	if err := storage.Copy("missing.txt", "copy.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("Copy() expected ErrNotFound, got:", err)
+	}
+}
+
+func testSize(t *testing.T, storage filesystem.StorageInterface) {
+	mustPut(t, storage, "test.txt", "12345")
+	mustPut(t, storage, "empty.txt", "")
+
+	size, err := storage.Size("test.txt")
+
+	if err != nil {
+		t.Fatal("Size() unexpected error:", err)
+	}
+
+	if size != 5 {
+		t.Fatal("Size() expected 5, got:", size)
+	}
+
+	size, err = storage.Size("empty.txt")
+
+	if err != nil {
+		t.Fatal("Size() unexpected error:", err)
+	}
+
+	if size != 0 {
+		t.Fatal("Size() expected 0, got:", size)
+	}
+}
+
+func testLastModified(t *testing.T, storage filesystem.StorageInterface) {
+	before := time.Now().Add(-time.Minute)
+
+	mustPut(t, storage, "test.txt", "test")
+
+	after := time.Now().Add(time.Minute)
+
+	modified, err := storage.LastModified("test.txt")
+
+	if err != nil {
+		t.Fatal("LastModified() unexpected error:", err)
+	}
+
+	if modified.Before(before) || modified.After(after) {
+		t.Fatal("LastModified() expected a time close to now, got:", modified)
+	}
+}
+
+func testStream(t *testing.T, storage filesystem.StorageInterface) {
+	streamStorage, ok := storage.(filesystem.StorageStreamInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StorageStreamInterface")
+	}
+
+	mustMakeDirectory(t, storage, "dir")
+
+	if err := streamStorage.PutStream("dir/known.txt", strings.NewReader("known size"), 10); err != nil {
+		t.Fatal("PutStream() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "dir/known.txt", "known size")
+
+	if err := streamStorage.PutStream("dir/unknown.txt", strings.NewReader("unknown size"), -1); err != nil {
+		t.Fatal("PutStream() unexpected error:", err)
+	}
+
+	assertContent(t, storage, "dir/unknown.txt", "unknown size")
+
+	reader, err := streamStorage.OpenReader("dir/known.txt")
+
+	if err != nil {
+		t.Fatal("OpenReader() unexpected error:", err)
+	}
+
+	data, err := io.ReadAll(reader)
+
+	if err != nil {
+		t.Fatal("OpenReader() unexpected read error:", err)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Fatal("OpenReader() unexpected close error:", err)
+	}
+
+	if string(data) != "known size" {
+		t.Fatalf("OpenReader() expected %q, got %q", "known size", string(data))
+	}
+
+	if _, err := streamStorage.OpenReader("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("OpenReader() expected ErrNotFound, got:", err)
+	}
+
+	writer, err := streamStorage.OpenWriter("dir/writer.txt")
+
+	if err != nil {
+		t.Fatal("OpenWriter() unexpected error:", err)
+	}
+
+	for _, chunk := range []string{"written ", "in ", "chunks"} {
+		if _, err := io.WriteString(writer, chunk); err != nil {
+			t.Fatal("OpenWriter() unexpected write error:", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal("OpenWriter() unexpected close error:", err)
+	}
+
+	assertContent(t, storage, "dir/writer.txt", "written in chunks")
+}
+
+func testRange(t *testing.T, storage filesystem.StorageInterface) {
+	rangeStorage, ok := storage.(filesystem.StorageRangeInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StorageRangeInterface")
+	}
+
+	mustPut(t, storage, "range.txt", "0123456789abcdef")
+	mustPut(t, storage, "empty.txt", "")
+
+	ranges := []struct {
+		offset   int64
+		length   int64
+		expected string
+	}{
+		{0, 4, "0123"},
+		{1, 1, "1"},
+		{5, 7, "56789ab"},
+		{10, -1, "abcdef"},
+		{14, 10, "ef"},
+		{16, 4, ""},
+		{100, -1, ""},
+		{3, 0, ""},
+	}
+
+	for _, r := range ranges {
+		data, err := rangeStorage.ReadRange("range.txt", r.offset, r.length)
+
+		if err != nil {
+			t.Fatalf("ReadRange(%d, %d) unexpected error: %v", r.offset, r.length, err)
+		}
+
+		if string(data) != r.expected {
+			t.Fatalf("ReadRange(%d, %d) expected %q, got %q", r.offset, r.length, r.expected, string(data))
+		}
+	}
+
+	data, err := rangeStorage.ReadRange("empty.txt", 0, -1)
+
+	if err != nil {
+		t.Fatal("ReadRange() unexpected error:", err)
+	}
+
+	if len(data) != 0 {
+		t.Fatalf("ReadRange() expected empty data, got %q", string(data))
+	}
+
+	if _, err := rangeStorage.ReadRange("missing.txt", 0, 1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("ReadRange() expected ErrNotFound, got:", err)
+	}
+
+	reader, err := rangeStorage.Open("range.txt")
+
+	if err != nil {
+		t.Fatal("Open() unexpected error:", err)
+	}
+
+	defer reader.Close()
+
+	if _, err := reader.Seek(10, io.SeekStart); err != nil {
+		t.Fatal("Seek() unexpected error:", err)
+	}
+
+	buffer := make([]byte, 3)
+
+	if _, err := io.ReadFull(reader, buffer); err != nil {
+		t.Fatal("Read() unexpected error:", err)
+	}
+
+	if string(buffer) != "abc" {
+		t.Fatalf("Read() after Seek() expected %q, got %q", "abc", string(buffer))
+	}
+
+	if _, err := reader.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal("Seek() unexpected error:", err)
+	}
+
+	rest, err := io.ReadAll(reader)
+
+	if err != nil {
+		t.Fatal("Read() unexpected error:", err)
+	}
+
+	if string(rest) != "cdef" {
+		t.Fatalf("Read() after Seek() expected %q, got %q", "cdef", string(rest))
+	}
+
+	readerAt, ok := reader.(io.ReaderAt)
+
+	if !ok {
+		t.Fatal("Open() expected reader to implement io.ReaderAt")
+	}
+
+	if _, err := readerAt.ReadAt(buffer, 2); err != nil {
+		t.Fatal("ReadAt() unexpected error:", err)
+	}
+
+	if string(buffer) != "234" {
+		t.Fatalf("ReadAt() expected %q, got %q", "234", string(buffer))
+	}
+}
+
+func testListPage(t *testing.T, storage filesystem.StorageInterface) {
+	pageStorage, ok := storage.(filesystem.StoragePageInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StoragePageInterface")
+	}
+
+	mustMakeDirectory(t, storage, "dir")
+	mustMakeDirectory(t, storage, "dir/sub1")
+	mustMakeDirectory(t, storage, "dir/sub2")
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		mustPut(t, storage, "dir/"+name+".txt", name)
+	}
+
+	mustPut(t, storage, "dir/sub1/nested.txt", "nested")
+
+	directories := []string{}
+	files := []string{}
+	cursor := ""
+
+	for i := 0; ; i++ {
+		if i > 10 {
+			t.Fatal("ListPage() did not reach the last page")
+		}
+
+		page, err := pageStorage.ListPage("dir", cursor, 3)
+
+		if err != nil {
+			t.Fatal("ListPage() unexpected error:", err)
+		}
+
+		if len(page.Directories)+len(page.Files) > 3 {
+			t.Fatalf("ListPage() expected at most 3 entries, got %d", len(page.Directories)+len(page.Files))
+		}
+
+		directories = append(directories, page.Directories...)
+		files = append(files, page.Files...)
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		cursor = page.NextCursor
+	}
+
+	assertPaths(t, "ListPage() directories", directories, []string{"dir/sub1", "dir/sub2"})
+	assertPaths(t, "ListPage() files", files, []string{"dir/a.txt", "dir/b.txt", "dir/c.txt", "dir/d.txt", "dir/e.txt"})
+
+	page, err := pageStorage.ListPage("dir", "", 0)
+
+	if err != nil {
+		t.Fatal("ListPage() unexpected error:", err)
+	}
+
+	if page.NextCursor != "" || len(page.Directories)+len(page.Files) != 7 {
+		t.Fatal("ListPage() with default limit expected all 7 entries in a single page")
+	}
+
+	// removing an entry of an earlier page does not skip or repeat the next entries
+	page, err = pageStorage.ListPage("dir", "", 2)
+
+	if err != nil {
+		t.Fatal("ListPage() unexpected error:", err)
+	}
+
+	files = page.Files
+
+	if err := storage.DeleteFile([]string{"dir/a.txt"}); err != nil {
+		t.Fatal("DeleteFile() unexpected error:", err)
+	}
+
+	for i := 0; page.NextCursor != ""; i++ {
+		if i > 10 {
+			t.Fatal("ListPage() did not reach the last page")
+		}
+
+		page, err = pageStorage.ListPage("dir", page.NextCursor, 2)
+
+		if err != nil {
+			t.Fatal("ListPage() unexpected error:", err)
+		}
+
+		files = append(files, page.Files...)
+	}
+
+	assertPaths(t, "ListPage() files", files, []string{"dir/a.txt", "dir/b.txt", "dir/c.txt", "dir/d.txt", "dir/e.txt"})
+}
+
+func testWalk(t *testing.T, storage filesystem.StorageInterface) {
+	walkStorage, ok := storage.(filesystem.StorageWalkInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StorageWalkInterface")
+	}
+
+	mustMakeDirectory(t, storage, "dir")
+	mustMakeDirectory(t, storage, "dir/sub")
+	mustMakeDirectory(t, storage, "dir/sub/deep")
+	mustMakeDirectory(t, storage, "dir/z")
+	mustPut(t, storage, "dir/a.txt", "a")
+	mustPut(t, storage, "dir/sub/b.txt", "b")
+	mustPut(t, storage, "dir/sub/deep/c.txt", "c")
+	mustPut(t, storage, "dir/z/d.txt", "d")
+	mustPut(t, storage, "other.txt", "other")
+
+	walk := func(root string, skip string, skipErr error) []string {
+		visited := []string{}
+
+		err := walkStorage.Walk(root, func(filePath string, isDir bool, err error) error {
+			if err != nil {
+				t.Fatal("Walk() unexpected error:", err)
+			}
+
+			visited = append(visited, filePath)
+
+			if filePath == skip {
+				return skipErr
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal("Walk() unexpected error:", err)
+		}
+
+		return visited
+	}
+
+	assertOrder := func(method string, actual, expected []string) {
+		t.Helper()
+
+		if strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Fatalf("%s expected %q, got %q", method, expected, actual)
+		}
+	}
+
+	assertOrder("Walk()", walk("dir", "", nil), []string{
+		"dir/a.txt", "dir/sub", "dir/sub/b.txt", "dir/sub/deep", "dir/sub/deep/c.txt", "dir/z", "dir/z/d.txt",
+	})
+
+	assertOrder("Walk() with SkipDir", walk("dir", "dir/sub", filesystem.SkipDir), []string{
+		"dir/a.txt", "dir/sub", "dir/z", "dir/z/d.txt",
+	})
+
+	assertOrder("Walk() with SkipDir on a file", walk("dir", "dir/sub/b.txt", filesystem.SkipDir), []string{
+		"dir/a.txt", "dir/sub", "dir/sub/b.txt", "dir/z", "dir/z/d.txt",
+	})
+
+	assertOrder("Walk() with SkipAll", walk("", "dir/sub", filesystem.SkipAll), []string{
+		"dir", "dir/a.txt", "dir/sub",
+	})
+
+	files, err := walkStorage.AllFiles("")
+
+	if err != nil {
+		t.Fatal("AllFiles() unexpected error:", err)
+	}
+
+	assertPaths(t, "AllFiles()", files, []string{"dir/a.txt", "dir/sub/b.txt", "dir/sub/deep/c.txt", "dir/z/d.txt", "other.txt"})
+
+	directories, err := walkStorage.AllDirectories("dir")
+
+	if err != nil {
+		t.Fatal("AllDirectories() unexpected error:", err)
+	}
+
+	assertPaths(t, "AllDirectories()", directories, []string{"dir/sub", "dir/sub/deep", "dir/z"})
+}
+
+func testStat(t *testing.T, storage filesystem.StorageInterface) {
+	statStorage, ok := storage.(filesystem.StorageStatInterface)
+
+	if !ok {
+		t.Skip("storage does not implement StorageStatInterface")
+	}
+
+	mustMakeDirectory(t, storage, "dir")
+	mustMakeDirectory(t, storage, "dir/sub")
+	mustPut(t, storage, "dir/file.txt", "hello")
+
+	info, err := statStorage.Stat("dir/file.txt")
+
+	if err != nil {
+		t.Fatal("Stat() unexpected error:", err)
+	}
+
+	if info.Name != "file.txt" || info.Path != "dir/file.txt" || info.Size != 5 || info.IsDir {
+		t.Fatalf("Stat() unexpected file info: %+v", info)
+	}
+
+	if !strings.HasPrefix(info.MimeType, "text/plain") {
+		t.Fatal("Stat() expected a text/plain MIME type, got:", info.MimeType)
+	}
+
+	if info.ETag == "" || info.ModTime.IsZero() || info.Visibility == "" {
+		t.Fatalf("Stat() expected an ETag, modification time and visibility: %+v", info)
+	}
+
+	dirInfo, err := statStorage.Stat("dir")
+
+	if err != nil {
+		t.Fatal("Stat() unexpected error:", err)
+	}
+
+	if dirInfo.Name != "dir" || dirInfo.Path != "dir" || !dirInfo.IsDir {
+		t.Fatalf("Stat() unexpected directory info: %+v", dirInfo)
+	}
+
+	if _, err := statStorage.Stat("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("Stat() expected ErrNotFound, got:", err)
+	}
+
+	infos, err := statStorage.List("dir")
+
+	if err != nil {
+		t.Fatal("List() unexpected error:", err)
+	}
+
+	if len(infos) != 2 {
+		t.Fatalf("List() expected 2 entries, got %+v", infos)
+	}
+
+	if infos[0].Path != "dir/file.txt" || infos[0].IsDir || infos[0].Size != 5 || infos[0].ETag == "" {
+		t.Fatalf("List() unexpected file info: %+v", infos[0])
+	}
+
+	if infos[1].Path != "dir/sub" || !infos[1].IsDir {
+		t.Fatalf("List() unexpected directory info: %+v", infos[1])
+	}
+}
+
+func mustPut(t *testing.T, storage filesystem.StorageInterface, filePath, content string) {
+	t.Helper()
+
+	if err := storage.Put(filePath, []byte(content)); err != nil {
+		t.Fatalf("Put(%q) unexpected error: %v", filePath, err)
+	}
+}
+
+func mustMakeDirectory(t *testing.T, storage filesystem.StorageInterface, dirPath string) {
+	t.Helper()
+
+	if err := storage.MakeDirectory(dirPath); err != nil {
+		t.Fatalf("MakeDirectory(%q) unexpected error: %v", dirPath, err)
+	}
+}
+
+func assertContent(t *testing.T, storage filesystem.StorageInterface, filePath, expected string) {
+	t.Helper()
+
+	data, err := storage.ReadFile(filePath)
+
+	if err != nil {
+		t.Fatalf("ReadFile(%q) unexpected error: %v", filePath, err)
+	}
+
+	if string(data) != expected {
+		t.Fatalf("ReadFile(%q) expected %q, got %q", filePath, expected, string(data))
+	}
+}
+
+func assertExists(t *testing.T, storage filesystem.StorageInterface, filePath string, expected bool) {
+	t.Helper()
+
+	exists, err := storage.Exists(filePath)
+
+	if err != nil {
+		t.Fatalf("Exists(%q) unexpected error: %v", filePath, err)
+	}
+
+	if exists != expected {
+		t.Fatalf("Exists(%q) expected %v, got %v", filePath, expected, exists)
+	}
+}
+
+func assertPaths(t *testing.T, method string, actual, expected []string) {
+	t.Helper()
+
+	sorted := append([]string{}, actual...)
+	sort.Strings(sorted)
+
+	if len(sorted) == 0 && len(expected) == 0 {
+		return
+	}
+
+	if !reflect.DeepEqual(sorted, expected) {
+		t.Fatalf("%s expected %q, got %q", method, expected, sorted)
+	}
+}
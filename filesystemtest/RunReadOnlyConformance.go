@@ -0,0 +1,135 @@
+package filesystemtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gouniverse/filesystem"
+)
+
+// ReadOnlyFactory creates a new read only storage holding the files,
+// keyed by their path, for a single test. Use t.Cleanup to release
+// any resources held by the storage.
+type ReadOnlyFactory func(t *testing.T, files map[string]string) filesystem.StorageInterface
+
+// readOnlyFiles are the files every read only storage is created with
+var readOnlyFiles = map[string]string{
+	"a.txt":         "hello world",
+	"empty.txt":     "",
+	"dir/b.txt":     "b",
+	"dir/sub/c.txt": "c",
+}
+
+// RunReadOnlyConformance runs the read checks of the conformance test
+// suite against the read only storages created by the factory, and
+// checks that every write fails with ErrReadOnly. Every check runs as
+// a sub-test with its own fresh storage.
+func RunReadOnlyConformance(t *testing.T, factory ReadOnlyFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, storage filesystem.StorageInterface)
+	}{
+		{"ReadFile", testReadOnlyReadFile},
+		{"Exists", testReadOnlyExists},
+		{"Size", testReadOnlySize},
+		{"LastModified", testReadOnlyLastModified},
+		{"NotFoundErrors", testReadOnlyNotFoundErrors},
+		{"WritesRejected", testReadOnlyWritesRejected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := map[string]string{}
+
+			for filePath, content := range readOnlyFiles {
+				files[filePath] = content
+			}
+
+			storage := factory(t, files)
+
+			if storage == nil {
+				t.Fatal("factory returned nil storage")
+			}
+
+			test.fn(t, storage)
+		})
+	}
+}
+
+func testReadOnlyReadFile(t *testing.T, storage filesystem.StorageInterface) {
+	for filePath, content := range readOnlyFiles {
+		assertContent(t, storage, filePath, content)
+	}
+
+	assertContent(t, storage, "/dir/b.txt", "b")
+}
+
+func testReadOnlyExists(t *testing.T, storage filesystem.StorageInterface) {
+	assertExists(t, storage, "a.txt", true)
+	assertExists(t, storage, "dir/sub/c.txt", true)
+	assertExists(t, storage, "missing.txt", false)
+	assertExists(t, storage, "missing/missing.txt", false)
+}
+
+func testReadOnlySize(t *testing.T, storage filesystem.StorageInterface) {
+	size, err := storage.Size("a.txt")
+
+	if err != nil {
+		t.Fatal("Size() unexpected error:", err)
+	}
+
+	if size != 11 {
+		t.Fatal("Size() expected 11, got:", size)
+	}
+
+	size, err = storage.Size("empty.txt")
+
+	if err != nil {
+		t.Fatal("Size() unexpected error:", err)
+	}
+
+	if size != 0 {
+		t.Fatal("Size() expected 0, got:", size)
+	}
+}
+
+func testReadOnlyLastModified(t *testing.T, storage filesystem.StorageInterface) {
+	if _, err := storage.LastModified("a.txt"); err != nil {
+		t.Fatal("LastModified() unexpected error:", err)
+	}
+}
+
+func testReadOnlyNotFoundErrors(t *testing.T, storage filesystem.StorageInterface) {
+	if _, err := storage.ReadFile("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("ReadFile() expected ErrNotFound, got:", err)
+	}
+
+	if _, err := storage.Size("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("Size() expected ErrNotFound, got:", err)
+	}
+
+	if _, err := storage.LastModified("missing.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatal("LastModified() expected ErrNotFound, got:", err)
+	}
+}
+
+func testReadOnlyWritesRejected(t *testing.T, storage filesystem.StorageInterface) {
+	writes := map[string]error{
+		"Put()":             storage.Put("a.txt", []byte("changed")),
+		"Copy()":            storage.Copy("a.txt", "copy.txt"),
+		"Move()":            storage.Move("a.txt", "moved.txt"),
+		"DeleteFile()":      storage.DeleteFile([]string{"a.txt"}),
+		"DeleteDirectory()": storage.DeleteDirectory("dir"),
+		"MakeDirectory()":   storage.MakeDirectory("new"),
+	}
+
+	for method, err := range writes {
+		if !errors.Is(err, filesystem.ErrReadOnly) {
+			t.Fatal(method, "expected ErrReadOnly, got:", err)
+		}
+	}
+
+	assertContent(t, storage, "a.txt", "hello world")
+	assertExists(t, storage, "dir/b.txt", true)
+	assertExists(t, storage, "copy.txt", false)
+}
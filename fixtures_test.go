@@ -0,0 +1,18 @@
+package filesystem
+
+import "testing"
+
+// newTestStorage creates the storage of the disk, failing the test on an error
+func newTestStorage(t *testing.T, disk Disk) StorageInterface {
+	storage, err := NewStorage(disk)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storage == nil {
+		t.Fatal("NewStorage() returned nil")
+	}
+
+	return storage
+}
@@ -0,0 +1,58 @@
+package filesystem
+
+import "sort"
+
+// pageEntry is an entry of a directory listing, used to build a Page
+type pageEntry struct {
+	path  string
+	isDir bool
+}
+
+// newPage builds the page of entries following the cursor. The cursor is
+// the path of the last entry of the previous page, so it stays valid when
+// entries are added or removed between the calls.
+func newPage(entries []pageEntry, cursor string, limit int) Page {
+	if limit <= 0 {
+		limit = DEFAULT_PAGE_LIMIT
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+
+	page := Page{Directories: []string{}, Files: []string{}}
+	count := 0
+
+	for _, entry := range entries {
+		if cursor != "" && entry.path <= cursor {
+			continue
+		}
+
+		if count == limit {
+			page.NextCursor = cursor
+			break
+		}
+
+		if entry.isDir {
+			page.Directories = append(page.Directories, entry.path)
+		} else {
+			page.Files = append(page.Files, entry.path)
+		}
+
+		cursor = entry.path
+		count++
+	}
+
+	return page
+}
+
+// toPageEntries converts the paths to page entries
+func toPageEntries(paths []string, isDir bool) []pageEntry {
+	entries := make([]pageEntry, len(paths))
+
+	for i, entryPath := range paths {
+		entries[i] = pageEntry{path: entryPath, isDir: isDir}
+	}
+
+	return entries
+}
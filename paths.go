@@ -0,0 +1,22 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+)
+
+// cleanPath normalizes a storage path to its canonical form: a slash
+// separated path relative to the storage root, without leading or
+// trailing slashes. The root itself is represented by an empty string.
+//
+// Any ".." elements are resolved against the root, so the returned
+// path can never point outside of it.
+func cleanPath(filePath string) string {
+	cleaned := path.Clean(PATH_SEPARATOR + filePath)
+	return strings.TrimPrefix(cleaned, PATH_SEPARATOR)
+}
+
+// joinUrl joins a base URL and a storage path with a single slash
+func joinUrl(baseUrl, filePath string) string {
+	return strings.TrimSuffix(baseUrl, PATH_SEPARATOR) + PATH_SEPARATOR + strings.TrimPrefix(filePath, PATH_SEPARATOR)
+}
@@ -0,0 +1,185 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// errNegativeOffset is returned when reading at a negative offset
+var errNegativeOffset = errors.New("negative offset")
+
+// rangeBounds clamps the requested range to the size of the file,
+// and returns the start and end (exclusive) offsets
+func rangeBounds(offset, length, size int64) (start int64, end int64, err error) {
+	if offset < 0 {
+		return 0, 0, errNegativeOffset
+	}
+
+	start = min(offset, size)
+	end = size
+
+	if length >= 0 && start+length < size {
+		end = start + length
+	}
+
+	return start, end, nil
+}
+
+// rangeReader implements io.ReadSeekCloser and io.ReaderAt on top of
+// a function, which reads a range of a file of known size. With a block
+// size, Read reads whole blocks and buffers them, so small reads do not
+// cost a range read each. ReadAt is not buffered.
+type rangeReader struct {
+	size         int64
+	offset       int64
+	readRange    func(offset, length int64) ([]byte, error)
+	blockSize    int64
+	buffer       []byte
+	bufferOffset int64
+	closed       bool
+}
+
+var _ io.ReadSeekCloser = (*rangeReader)(nil)
+var _ io.ReaderAt = (*rangeReader)(nil)
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.blockSize > 0 {
+		return r.readBuffered(p)
+	}
+
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+
+	if err == io.EOF && n > 0 {
+		return n, nil
+	}
+
+	return n, err
+}
+
+// readBuffered reads from the buffered block, reading the block
+// holding the offset first, when the offset is outside of it
+func (r *rangeReader) readBuffered(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if r.offset < r.bufferOffset || r.offset >= r.bufferOffset+int64(len(r.buffer)) {
+		start := r.offset - r.offset%r.blockSize
+
+		data, err := r.readRange(start, r.blockSize)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if r.offset >= start+int64(len(data)) { // shorter than the known size
+			return 0, io.EOF
+		}
+
+		r.buffer = data
+		r.bufferOffset = start
+	}
+
+	n := copy(p, r.buffer[r.offset-r.bufferOffset:])
+	r.offset += int64(n)
+
+	return n, nil
+}
+
+func (r *rangeReader) ReadAt(p []byte, offset int64) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	data, err := r.readRange(offset, int64(len(p)))
+
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, data)
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	position, err := seekPosition(r.offset, r.size, offset, whence)
+
+	if err != nil {
+		return 0, err
+	}
+
+	r.offset = position
+
+	return position, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.closed {
+		return os.ErrClosed
+	}
+
+	r.closed = true
+	r.buffer = nil
+
+	return nil
+}
+
+// seekPosition calculates the new position of a seek operation
+func seekPosition(current, size, offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += current
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+
+	return offset, nil
+}
+
+// bytesReadSeekCloser adds a no-op Close to a bytes reader
+type bytesReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadSeekCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,137 @@
+package filesystem
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// walkEntries calls fn for the entries in walk order, honouring SkipDir
+// and SkipAll. It is used by the drivers, which list all the entries
+// under the root in a single (flat) listing.
+func walkEntries(entries []pageEntry, fn WalkFunc) error {
+	// sorting with the separator as the lowest character puts every
+	// directory right before its contents, i.e. "a", "a/b", "a.txt"
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ReplaceAll(entries[i].path, "/", "\x00") < strings.ReplaceAll(entries[j].path, "/", "\x00")
+	})
+
+	skipped := []string{} // prefixes of the skipped directories
+
+	for _, entry := range entries {
+		if isSkipped(entry.path, skipped) {
+			continue
+		}
+
+		err := fn(entry.path, entry.isDir, nil)
+
+		if errors.Is(err, SkipAll) {
+			return nil
+		}
+
+		if errors.Is(err, SkipDir) {
+			if entry.isDir {
+				skipped = append(skipped, entry.path+"/")
+			} else {
+				skipped = append(skipped, parentPrefix(entry.path))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkRootError reports the failure to list the root to fn
+func walkRootError(root string, err error, fn WalkFunc) error {
+	err = fn(cleanPath(root), true, err)
+
+	if errors.Is(err, SkipDir) || errors.Is(err, SkipAll) {
+		return nil
+	}
+
+	return err
+}
+
+// walkAll collects the paths of the files, or the directories, under the root
+func walkAll(walk func(root string, fn WalkFunc) error, root string, directories bool) ([]string, error) {
+	paths := []string{}
+
+	err := walk(root, func(filePath string, isDir bool, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if isDir == directories {
+			paths = append(paths, filePath)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return []string{}, err
+	}
+
+	return paths, nil
+}
+
+// isSkipped checks if the path is inside any of the skipped prefixes
+func isSkipped(filePath string, skipped []string) bool {
+	for _, prefix := range skipped {
+		if prefix == "" || strings.HasPrefix(filePath, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parentPrefix returns the prefix of the entries in the same directory as the path
+func parentPrefix(filePath string) string {
+	index := strings.LastIndex(filePath, "/")
+
+	if index < 0 {
+		return ""
+	}
+
+	return filePath[:index+1]
+}
+
+// allFiles lists all the files under the directory, natively when the
+// storage implements StorageWalkInterface, otherwise with Files and
+// Directories, one directory at a time
+func allFiles(storage StorageInterface, dir string) ([]string, error) {
+	if walkStorage, ok := storage.(StorageWalkInterface); ok {
+		return walkStorage.AllFiles(dir)
+	}
+
+	files, err := storage.Files(dir)
+
+	if err != nil {
+		return []string{}, err
+	}
+
+	directories, err := storage.Directories(dir)
+
+	if err != nil {
+		return []string{}, err
+	}
+
+	for _, directory := range directories {
+		directoryFiles, err := allFiles(storage, directory)
+
+		if err != nil {
+			return []string{}, err
+		}
+
+		files = append(files, directoryFiles...)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,129 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+)
+
+// bufferWriter collects the written data in memory, and
+// passes it to the flush function when closed
+type bufferWriter struct {
+	buffer bytes.Buffer
+	flush  func(content []byte) error
+	closed bool
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	return w.buffer.Write(p)
+}
+
+func (w *bufferWriter) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.closed = true
+
+	return w.flush(w.buffer.Bytes())
+}
+
+// tempFileWriter spools the written data to a temporary file, and
+// passes it to the flush function when closed. The temporary file
+// is removed afterwards.
+type tempFileWriter struct {
+	file   *os.File
+	size   int64
+	flush  func(file *os.File, size int64) error
+	closed bool
+}
+
+func newTempFileWriter(flush func(file *os.File, size int64) error) (*tempFileWriter, error) {
+	file, err := os.CreateTemp("", "filesystem-*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tempFileWriter{file: file, flush: flush}, nil
+}
+
+func (w *tempFileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *tempFileWriter) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.closed = true
+
+	defer os.Remove(w.file.Name())
+	defer w.file.Close()
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return w.flush(w.file, w.size)
+}
+
+// detectContentType detects the content type from the first 512 bytes
+// of the reader, and returns a reader, which still yields all the data
+func detectContentType(reader io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		position, err := seeker.Seek(0, io.SeekCurrent)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		n, err := io.ReadFull(seeker, head)
+
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", nil, err
+		}
+
+		if _, err := seeker.Seek(position, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+
+		return http.DetectContentType(head[:n]), seeker, nil
+	}
+
+	buffered := bufio.NewReaderSize(reader, len(head))
+	peeked, err := buffered.Peek(len(head))
+
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+
+	return http.DetectContentType(peeked), buffered, nil
+}
+
+// tempFile is a temporary file, which is removed when closed
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	defer os.Remove(f.Name())
+
+	return f.File.Close()
+}